@@ -0,0 +1,170 @@
+package cudavec
+
+import (
+	"math"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// Attention describes scaled dot-product attention over
+// [Batch, Heads, SeqLen, Dim] tensors stored in vectors
+// from a Creator32.
+//
+// The queries, keys, and values all have the same shape.
+// The full attention matrix is never stored; keys are
+// processed in tiles with an online softmax.
+type Attention struct {
+	Batch  int
+	Heads  int
+	SeqLen int
+	Dim    int
+
+	// Causal prevents each query from attending to keys
+	// that come after it in the sequence.
+	Causal bool
+
+	// Scale is multiplied by every query-key dot product.
+	// If it is 0, 1/sqrt(Dim) is used.
+	Scale float32
+}
+
+// Forward computes the attention outputs and writes them
+// to out.
+//
+// It returns the log-sum-exp of each query's scores,
+// which is needed by Backward.
+func (a *Attention) Forward(q, k, v, out anyvec.Vector) anyvec.Vector {
+	a.checkShapes(q, k, v, out)
+	q32, k32, v32, out32 := q.(*vector32), k.(*vector32), v.(*vector32), out.(*vector32)
+	q32.creator.Handle.sanitize([]operand{out32}, []operand{q32, k32, v32})
+	if v.Overlaps(out) || q.Overlaps(out) || k.Overlaps(out) {
+		panic("invalid overlap")
+	}
+	logSumExp := q32.creator.MakeVector(a.Batch * a.Heads * a.SeqLen).(*vector32)
+	if q.Len() == 0 {
+		return logSumExp
+	}
+	out32.run([]*vector32{out32, logSumExp}, func() error {
+		if err := lazyInitAll(true, q32, k32, v32, out32, logSumExp); err != nil {
+			return err
+		}
+		h := q32.creator.Handle
+		threads := a.threads()
+		gridX, gridY := a.grid(h)
+		return h.launch("attentionForward", gridX, gridY, 1, threads, 1, 1,
+			threads*4*2, nil, out32.buffer, logSumExp.buffer, q32.buffer, k32.buffer,
+			v32.buffer, int64(a.Batch*a.Heads), a.SeqLen, a.Dim, a.scale(),
+			a.causalFlag())
+	})
+	return logSumExp
+}
+
+// Backward propagates the gradient of Forward.
+//
+// The out and logSumExp arguments are the results of
+// Forward.
+// The gradients with respect to the queries, keys, and
+// values are added to qGrad, kGrad, and vGrad.
+func (a *Attention) Backward(q, k, v, out, logSumExp, outGrad, qGrad, kGrad,
+	vGrad anyvec.Vector) {
+	a.checkShapes(q, k, v, out)
+	a.checkShapes(outGrad, qGrad, kGrad, vGrad)
+	if logSumExp.Len() != a.Batch*a.Heads*a.SeqLen {
+		panic("bad log-sum-exp size")
+	}
+	inputs := []anyvec.Vector{q, k, v, out, logSumExp, outGrad}
+	outputs := []anyvec.Vector{qGrad, kGrad, vGrad}
+	var writes, reads []operand
+	for _, input := range inputs {
+		reads = append(reads, input.(*vector32))
+	}
+	for _, output := range outputs {
+		writes = append(writes, output.(*vector32))
+	}
+	q.(*vector32).creator.Handle.sanitize(writes, reads)
+	for i, output := range outputs {
+		for _, other := range append(inputs, outputs[:i]...) {
+			if output.Overlaps(other) {
+				panic("invalid overlap")
+			}
+		}
+	}
+	if q.Len() == 0 {
+		return
+	}
+	q32, k32, v32, out32 := q.(*vector32), k.(*vector32), v.(*vector32), out.(*vector32)
+	logSumExp32, outGrad32 := logSumExp.(*vector32), outGrad.(*vector32)
+	qGrad32, kGrad32, vGrad32 := qGrad.(*vector32), kGrad.(*vector32), vGrad.(*vector32)
+	qGrad32.run([]*vector32{qGrad32, kGrad32, vGrad32}, func() error {
+		err := lazyInitAll(true, q32, k32, v32, out32, logSumExp32, outGrad32,
+			qGrad32, kGrad32, vGrad32)
+		if err != nil {
+			return err
+		}
+		h := q32.creator.Handle
+		threads := a.threads()
+		gridX, gridY := a.grid(h)
+		return h.launch("attentionBackward", gridX, gridY, 1, threads, 1, 1,
+			threads*4*3, nil, qGrad32.buffer, kGrad32.buffer, vGrad32.buffer,
+			outGrad32.buffer, out32.buffer, logSumExp32.buffer, q32.buffer, k32.buffer,
+			v32.buffer, int64(a.Batch*a.Heads), a.SeqLen, a.Dim, a.scale(),
+			a.causalFlag())
+	})
+}
+
+func (a *Attention) checkShapes(q, k, v, out anyvec.Vector) {
+	if a.Batch < 0 || a.Heads < 0 || a.SeqLen < 0 || a.Dim < 0 {
+		panic("attention dimensions cannot be negative")
+	}
+
+	// Every partial product is checked, so that the size
+	// cannot overflow before it is compared.
+	size := 1
+	for _, factor := range []int{a.Batch, a.Heads, a.SeqLen, a.Dim} {
+		if factor > math.MaxInt32 {
+			panic("attention size is too big")
+		}
+		size *= factor
+		if size > math.MaxInt32 {
+			panic("attention size is too big")
+		}
+	}
+	for _, x := range []anyvec.Vector{q, k, v, out} {
+		if x.Len() != size {
+			panic("bad attention tensor size")
+		}
+	}
+}
+
+func (a *Attention) scale() float32 {
+	if a.Scale == 0 {
+		return float32(1 / math.Sqrt(float64(a.Dim)))
+	}
+	return a.Scale
+}
+
+func (a *Attention) causalFlag() int {
+	if a.Causal {
+		return 1
+	}
+	return 0
+}
+
+// grid finds the launch grid for the attention kernels,
+// which loop over the queries along x and the heads along
+// y.
+func (a *Attention) grid(h *Handle) (gridX, gridY uint) {
+	maxY := uint(defaultMaxGrid)
+	if h.maxGrid < maxY {
+		maxY = h.maxGrid
+	}
+	return capGrid(a.SeqLen, h.maxGrid), capGrid(a.Batch*a.Heads, maxY)
+}
+
+func (a *Attention) threads() uint {
+	threads := normThreads(a.SeqLen)
+	if threads > 128 {
+		threads = 128
+	}
+	return threads
+}
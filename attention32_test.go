@@ -0,0 +1,122 @@
+package cudavec
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAttention(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	for i, causal := range []bool{false, true, false} {
+		if i == 2 {
+			// With a tiny grid, each block must loop over
+			// several queries and heads.
+			defer setMaxGrid(handle, setMaxGrid(handle, 3))
+		}
+
+		// Use a sequence longer than one tile of keys.
+		attn := &Attention{Batch: 2, Heads: 2, SeqLen: 150, Dim: 5, Causal: causal}
+		size := attn.Batch * attn.Heads * attn.SeqLen * attn.Dim
+		q, k, v := randomSlice(size), randomSlice(size), randomSlice(size)
+		outGrad := randomSlice(size)
+
+		expOut, expQGrad, expKGrad, expVGrad := attentionReference(attn, q, k, v, outGrad)
+
+		qVec, kVec, vVec := c.MakeVectorData(q), c.MakeVectorData(k), c.MakeVectorData(v)
+		out := c.MakeVector(size)
+		logSumExp := attn.Forward(qVec, kVec, vVec, out)
+		assertClose(t, "output", out.Data().([]float32), expOut)
+
+		qGrad, kGrad, vGrad := c.MakeVector(size), c.MakeVector(size), c.MakeVector(size)
+		attn.Backward(qVec, kVec, vVec, out, logSumExp, c.MakeVectorData(outGrad),
+			qGrad, kGrad, vGrad)
+		assertClose(t, "query gradient", qGrad.Data().([]float32), expQGrad)
+		assertClose(t, "key gradient", kGrad.Data().([]float32), expKGrad)
+		assertClose(t, "value gradient", vGrad.Data().([]float32), expVGrad)
+	}
+}
+
+func TestAttentionCheckShapes(t *testing.T) {
+	// More heads than a grid dimension of older devices.
+	attn := &Attention{Batch: 512, Heads: 128, SeqLen: 2, Dim: 2}
+	vec := &vector32{size: 512 * 128 * 2 * 2}
+	attn.checkShapes(vec, vec, vec, vec)
+
+	empty := &vector32{}
+	for i, attn := range []*Attention{
+		{Batch: 1 << 31, Heads: 1},
+		{Batch: 1 << 20, Heads: 1 << 20},
+		{Batch: 1 << 16, Heads: 1 << 16, SeqLen: 1 << 16, Dim: 1 << 16},
+		{Batch: 1, Heads: 1, SeqLen: 1 << 32},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("case %d: expected panic", i)
+				}
+			}()
+			attn.checkShapes(empty, empty, empty, empty)
+		}()
+	}
+}
+
+// attentionReference computes attention and its gradients
+// on the host by materializing the score matrix.
+func attentionReference(a *Attention, q, k, v, outGrad []float32) (out, qGrad, kGrad,
+	vGrad []float32) {
+	out = make([]float32, len(q))
+	qGrad = make([]float32, len(q))
+	kGrad = make([]float32, len(q))
+	vGrad = make([]float32, len(q))
+	scale := float64(a.scale())
+	for head := 0; head < a.Batch*a.Heads; head++ {
+		base := head * a.SeqLen * a.Dim
+		vecAt := func(data []float32, i int) []float32 {
+			return data[base+i*a.Dim : base+(i+1)*a.Dim]
+		}
+		for i := 0; i < a.SeqLen; i++ {
+			numKeys := a.SeqLen
+			if a.Causal {
+				numKeys = i + 1
+			}
+			probs := make([]float64, numKeys)
+			maxScore := math.Inf(-1)
+			for j := range probs {
+				probs[j] = scale * dotFloat32(vecAt(q, i), vecAt(k, j))
+				maxScore = math.Max(maxScore, probs[j])
+			}
+			var sum float64
+			for j := range probs {
+				probs[j] = math.Exp(probs[j] - maxScore)
+				sum += probs[j]
+			}
+			probGrads := make([]float64, numKeys)
+			var delta float64
+			for j := range probs {
+				probs[j] /= sum
+				probGrads[j] = dotFloat32(vecAt(outGrad, i), vecAt(v, j))
+				delta += probs[j] * probGrads[j]
+			}
+			for j, prob := range probs {
+				scoreGrad := prob * (probGrads[j] - delta)
+				for d := 0; d < a.Dim; d++ {
+					vecAt(out, i)[d] += float32(prob) * vecAt(v, j)[d]
+					vecAt(qGrad, i)[d] += float32(scale*scoreGrad) * vecAt(k, j)[d]
+					vecAt(kGrad, j)[d] += float32(scale*scoreGrad) * vecAt(q, i)[d]
+					vecAt(vGrad, j)[d] += float32(prob) * vecAt(outGrad, i)[d]
+				}
+			}
+		}
+	}
+	return
+}
+
+func dotFloat32(v1, v2 []float32) float64 {
+	var res float64
+	for i, x := range v1 {
+		res += float64(x) * float64(v2[i])
+	}
+	return res
+}
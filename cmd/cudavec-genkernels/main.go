@@ -0,0 +1,149 @@
+// Command cudavec-genkernels compiles the CUDA kernels for
+// cudavec into PTX for several architectures and writes a
+// manifest of the kernel signatures.
+//
+// It is run by go generate in the cudavec package, and it
+// requires nvcc from the CUDA toolkit, which links the math
+// functions that the kernels use from libdevice.
+// The default architectures need CUDA 11, since CUDA 12
+// dropped compute_35.
+//
+// Every PTX file is checked against the kernel signatures
+// before the manifest is written.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/unixpickle/cudavec/internal/kernelsig"
+)
+
+const defaultArchs = "compute_35,compute_50,compute_60,compute_70,compute_80"
+
+func main() {
+	var src, outDir, archs string
+	var manifestOnly bool
+	flag.StringVar(&src, "src", "kernels32.cu", "CUDA source file")
+	flag.StringVar(&outDir, "out", "kernels", "output directory")
+	flag.StringVar(&archs, "arch", defaultArchs, "comma-separated virtual architectures")
+	flag.BoolVar(&manifestOnly, "manifest-only", false,
+		"only rewrite the manifest for the existing PTX files")
+	flag.Parse()
+
+	source, err := ioutil.ReadFile(src)
+	if err != nil {
+		die(err)
+	}
+	kernels, err := kernelsig.Parse(string(source))
+	if err != nil {
+		die(err)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		die(err)
+	}
+
+	var archList []string
+	if manifestOnly {
+		archList, err = existingArchs(outDir)
+	} else {
+		archList = strings.Split(archs, ",")
+		err = compileAll(src, outDir, archList)
+	}
+	if err != nil {
+		die(err)
+	}
+	for _, arch := range archList {
+		path := filepath.Join(outDir, ptxName(src, arch))
+		ptx, err := ioutil.ReadFile(path)
+		if err != nil {
+			die(err)
+		}
+		if err := kernelsig.CheckPTX(kernels, string(ptx)); err != nil {
+			die(fmt.Errorf("%s: %s", path, err))
+		}
+	}
+
+	manifest := &kernelsig.Manifest{
+		Source:  filepath.Base(src),
+		Archs:   archList,
+		Kernels: kernels,
+	}
+	f, err := os.Create(filepath.Join(outDir, kernelsig.ManifestName))
+	if err != nil {
+		die(err)
+	}
+	defer f.Close()
+	if err := manifest.Write(f); err != nil {
+		die(err)
+	}
+}
+
+// compileAll replaces all of the PTX files in outDir with
+// new ones for the given architectures.
+func compileAll(src, outDir string, archs []string) error {
+	for _, arch := range archs {
+		if !strings.HasPrefix(arch, "compute_") {
+			return fmt.Errorf("bad architecture: %s", arch)
+		}
+	}
+	if _, err := exec.LookPath("nvcc"); err != nil {
+		return fmt.Errorf("nvcc from the CUDA toolkit is required: %s", err)
+	}
+	old, err := filepath.Glob(filepath.Join(outDir, "*.ptx"))
+	if err != nil {
+		return err
+	}
+	for _, path := range old {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	for _, arch := range archs {
+		out := filepath.Join(outDir, ptxName(src, arch))
+		cmd := exec.Command("nvcc", "--gpu-architecture="+arch, "--gpu-code="+arch,
+			"--ptx", "-o", out, src)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("compile %s: %s", arch, err)
+		}
+	}
+	return nil
+}
+
+// existingArchs finds the architectures of the PTX files
+// that are already in outDir.
+func existingArchs(outDir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(outDir, "*.ptx"))
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".ptx")
+		idx := strings.Index(name, "_compute_")
+		if idx < 0 {
+			return nil, fmt.Errorf("unexpected PTX file: %s", path)
+		}
+		res = append(res, name[idx+1:])
+	}
+	return res, nil
+}
+
+// ptxName gets the name of the PTX file for a source file
+// and architecture, such as kernels32_compute_50.ptx.
+func ptxName(src, arch string) string {
+	base := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	return base + "_" + arch + ".ptx"
+}
+
+func die(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
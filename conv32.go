@@ -0,0 +1,201 @@
+package cudavec
+
+import "github.com/unixpickle/anyvec"
+
+// Conv2D describes a 2D convolution over batches of NHWC
+// images stored in vectors from a Creator32.
+//
+// Filters are stored as a [FilterCount, FilterHeight,
+// FilterWidth, InputDepth] tensor.
+// Outputs are NHWC images with FilterCount channels.
+//
+// The zero values of StrideX and StrideY are treated as 1.
+// Padding is applied symmetrically with zeros.
+type Conv2D struct {
+	InputWidth  int
+	InputHeight int
+	InputDepth  int
+
+	FilterWidth  int
+	FilterHeight int
+	FilterCount  int
+
+	StrideX int
+	StrideY int
+
+	PaddingX int
+	PaddingY int
+}
+
+// OutputWidth returns the width of the output images.
+func (c *Conv2D) OutputWidth() int {
+	return (c.InputWidth+2*c.PaddingX-c.FilterWidth)/c.strideX() + 1
+}
+
+// OutputHeight returns the height of the output images.
+func (c *Conv2D) OutputHeight() int {
+	return (c.InputHeight+2*c.PaddingY-c.FilterHeight)/c.strideY() + 1
+}
+
+// Im2Col writes every filter-sized patch of a batch of
+// images into the rows of a matrix.
+//
+// The resulting matrix has one row per output position,
+// and each row is an [FilterHeight, FilterWidth,
+// InputDepth] patch.
+// Patch entries outside the image are zero.
+func (c *Conv2D) Im2Col(batch int, img, cols anyvec.Vector) {
+	c.checkShapes(batch)
+	if img.Len() != batch*c.inputSize() {
+		panic("bad image size")
+	} else if cols.Len() != batch*c.colsSize() {
+		panic("bad column matrix size")
+	}
+	img32 := img.(*vector32)
+	cols32 := cols.(*vector32)
+	img32.creator.Handle.sanitize([]operand{cols32}, []operand{img32})
+	if img.Overlaps(cols) {
+		panic("invalid overlap")
+	}
+	cols32.run([]*vector32{cols32}, func() error {
+		if err := lazyInitAll(true, img32, cols32); err != nil {
+			return err
+		}
+		return c.launch("im2col", cols32.Len(), cols32, img32)
+	})
+}
+
+// Col2Im is the transpose of Im2Col.
+//
+// It adds every patch in the matrix back to the area of
+// the images it came from.
+// The result is accumulated into img.
+func (c *Conv2D) Col2Im(batch int, cols, img anyvec.Vector) {
+	c.checkShapes(batch)
+	if img.Len() != batch*c.inputSize() {
+		panic("bad image size")
+	} else if cols.Len() != batch*c.colsSize() {
+		panic("bad column matrix size")
+	}
+	img32 := img.(*vector32)
+	cols32 := cols.(*vector32)
+	img32.creator.Handle.sanitize([]operand{img32}, []operand{cols32})
+	if img.Overlaps(cols) {
+		panic("invalid overlap")
+	}
+	img32.run([]*vector32{img32}, func() error {
+		if err := lazyInitAll(true, img32, cols32); err != nil {
+			return err
+		}
+		return c.launch("col2im", cols32.Len(), img32, cols32)
+	})
+}
+
+// Forward applies the filters to a batch of images and
+// writes the result to out.
+func (c *Conv2D) Forward(batch int, filters, img, out anyvec.Vector) {
+	c.checkShapes(batch)
+	if filters.Len() != c.FilterCount*c.patchSize() {
+		panic("bad filter size")
+	} else if out.Len() != batch*c.outputSize() {
+		panic("bad output size")
+	}
+	cols := img.Creator().MakeVector(batch * c.colsSize())
+	c.Im2Col(batch, img, cols)
+	rows := batch * c.OutputWidth() * c.OutputHeight()
+	out.(*vector32).Gemm(false, true, rows, c.FilterCount, c.patchSize(),
+		float32(1), cols, c.patchSize(), filters, c.patchSize(),
+		float32(0), c.FilterCount)
+}
+
+// BackwardData propagates the output gradient through the
+// convolution and adds the result to imgGrad.
+func (c *Conv2D) BackwardData(batch int, filters, outGrad, imgGrad anyvec.Vector) {
+	c.checkShapes(batch)
+	if filters.Len() != c.FilterCount*c.patchSize() {
+		panic("bad filter size")
+	} else if outGrad.Len() != batch*c.outputSize() {
+		panic("bad output gradient size")
+	}
+	rows := batch * c.OutputWidth() * c.OutputHeight()
+	cols := imgGrad.Creator().MakeVector(batch * c.colsSize()).(*vector32)
+	cols.Gemm(false, false, rows, c.patchSize(), c.FilterCount,
+		float32(1), outGrad, c.FilterCount, filters, c.patchSize(),
+		float32(0), c.patchSize())
+	c.Col2Im(batch, cols, imgGrad)
+}
+
+// BackwardFilters computes the gradient of the filters
+// given the output gradient and adds it to filterGrad.
+func (c *Conv2D) BackwardFilters(batch int, img, outGrad, filterGrad anyvec.Vector) {
+	c.checkShapes(batch)
+	if filterGrad.Len() != c.FilterCount*c.patchSize() {
+		panic("bad filter gradient size")
+	} else if outGrad.Len() != batch*c.outputSize() {
+		panic("bad output gradient size")
+	}
+	rows := batch * c.OutputWidth() * c.OutputHeight()
+	cols := img.Creator().MakeVector(batch * c.colsSize())
+	c.Im2Col(batch, img, cols)
+	filterGrad.(*vector32).Gemm(true, false, c.FilterCount, c.patchSize(), rows,
+		float32(1), outGrad, c.FilterCount, cols, c.patchSize(),
+		float32(1), c.patchSize())
+}
+
+func (c *Conv2D) launch(kernel string, n int, dst, src *vector32) error {
+	return dst.creator.Handle.launchFlat(kernel, n,
+		dst.buffer, src.buffer, n, c.InputWidth, c.InputHeight, c.InputDepth,
+		c.OutputWidth(), c.OutputHeight(), c.FilterWidth, c.FilterHeight,
+		c.strideX(), c.strideY(), c.PaddingX, c.PaddingY)
+}
+
+func (c *Conv2D) checkShapes(batch int) {
+	if batch < 0 {
+		panic("batch size cannot be negative")
+	} else if c.InputWidth < 0 || c.InputHeight < 0 || c.InputDepth < 0 {
+		panic("input dimensions cannot be negative")
+	} else if c.FilterWidth <= 0 || c.FilterHeight <= 0 || c.FilterCount < 0 {
+		panic("filter dimensions out of range")
+	} else if c.StrideX < 0 || c.StrideY < 0 {
+		panic("stride cannot be negative")
+	} else if c.PaddingX < 0 || c.PaddingY < 0 {
+		panic("padding cannot be negative")
+	} else if c.InputWidth+2*c.PaddingX < c.FilterWidth ||
+		c.InputHeight+2*c.PaddingY < c.FilterHeight {
+		panic("filter does not fit in padded input")
+	}
+	size := int64(batch) * int64(c.colsSize())
+	if int64(int32(size)) != size {
+		panic("convolution size is too big")
+	}
+}
+
+func (c *Conv2D) strideX() int {
+	if c.StrideX == 0 {
+		return 1
+	}
+	return c.StrideX
+}
+
+func (c *Conv2D) strideY() int {
+	if c.StrideY == 0 {
+		return 1
+	}
+	return c.StrideY
+}
+
+func (c *Conv2D) patchSize() int {
+	return c.FilterWidth * c.FilterHeight * c.InputDepth
+}
+
+func (c *Conv2D) inputSize() int {
+	return c.InputWidth * c.InputHeight * c.InputDepth
+}
+
+func (c *Conv2D) outputSize() int {
+	return c.OutputWidth() * c.OutputHeight() * c.FilterCount
+}
+
+func (c *Conv2D) colsSize() int {
+	return c.OutputWidth() * c.OutputHeight() * c.patchSize()
+}
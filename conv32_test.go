@@ -0,0 +1,133 @@
+package cudavec
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConv2D(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	conv := &Conv2D{
+		InputWidth:   7,
+		InputHeight:  6,
+		InputDepth:   3,
+		FilterWidth:  3,
+		FilterHeight: 2,
+		FilterCount:  4,
+		StrideX:      2,
+		StrideY:      1,
+		PaddingX:     1,
+		PaddingY:     1,
+	}
+	batch := 2
+	outSize := batch * conv.OutputWidth() * conv.OutputHeight() * conv.FilterCount
+
+	img := randomSlice(batch * conv.InputWidth * conv.InputHeight * conv.InputDepth)
+	filters := randomSlice(conv.FilterCount * conv.FilterWidth * conv.FilterHeight *
+		conv.InputDepth)
+	outGrad := randomSlice(outSize)
+
+	expectedOut, expectedImgGrad, expectedFilterGrad := convReference(conv, batch,
+		img, filters, outGrad)
+
+	actualOut := c.MakeVector(outSize)
+	conv.Forward(batch, c.MakeVectorData(filters), c.MakeVectorData(img), actualOut)
+	assertClose(t, "forward", actualOut.Data().([]float32), expectedOut)
+
+	actualImgGrad := c.MakeVector(len(img))
+	conv.BackwardData(batch, c.MakeVectorData(filters), c.MakeVectorData(outGrad),
+		actualImgGrad)
+	assertClose(t, "data gradient", actualImgGrad.Data().([]float32), expectedImgGrad)
+
+	actualFilterGrad := c.MakeVector(len(filters))
+	conv.BackwardFilters(batch, c.MakeVectorData(img), c.MakeVectorData(outGrad),
+		actualFilterGrad)
+	assertClose(t, "filter gradient", actualFilterGrad.Data().([]float32),
+		expectedFilterGrad)
+}
+
+// convReference computes a convolution and its gradients
+// on the host.
+func convReference(c *Conv2D, batch int, img, filters, outGrad []float32) (out,
+	imgGrad, filterGrad []float32) {
+	outW, outH := c.OutputWidth(), c.OutputHeight()
+	out = make([]float32, batch*outW*outH*c.FilterCount)
+	imgGrad = make([]float32, len(img))
+	filterGrad = make([]float32, len(filters))
+	for b := 0; b < batch; b++ {
+		for oy := 0; oy < outH; oy++ {
+			for ox := 0; ox < outW; ox++ {
+				for f := 0; f < c.FilterCount; f++ {
+					outIdx := ((b*outH+oy)*outW+ox)*c.FilterCount + f
+					for fy := 0; fy < c.FilterHeight; fy++ {
+						for fx := 0; fx < c.FilterWidth; fx++ {
+							x := ox*c.strideX() + fx - c.PaddingX
+							y := oy*c.strideY() + fy - c.PaddingY
+							if x < 0 || y < 0 || x >= c.InputWidth || y >= c.InputHeight {
+								continue
+							}
+							for z := 0; z < c.InputDepth; z++ {
+								imgIdx := ((b*c.InputHeight+y)*c.InputWidth+x)*c.InputDepth + z
+								filterIdx := ((f*c.FilterHeight+fy)*c.FilterWidth+fx)*
+									c.InputDepth + z
+								out[outIdx] += img[imgIdx] * filters[filterIdx]
+								imgGrad[imgIdx] += outGrad[outIdx] * filters[filterIdx]
+								filterGrad[filterIdx] += outGrad[outIdx] * img[imgIdx]
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func randomSlice(size int) []float32 {
+	res := make([]float32, size)
+	for i := range res {
+		res[i] = float32(rand.NormFloat64())
+	}
+	return res
+}
+
+func assertClose(t *testing.T, name string, actual, expected []float32) {
+	if len(actual) != len(expected) {
+		t.Fatalf("%s: expected length %d but got %d", name, len(expected), len(actual))
+	}
+	for i, x := range expected {
+		a := actual[i]
+		if math.IsNaN(float64(a)) || math.Abs(float64(a-x)) > 1e-3 {
+			t.Fatalf("%s: entry %d: expected %f but got %f", name, i, x, a)
+		}
+	}
+}
+
+func TestConv2DCheckShapes(t *testing.T) {
+	valid := Conv2D{InputWidth: 2, InputHeight: 2, InputDepth: 1, FilterWidth: 3,
+		FilterHeight: 3, FilterCount: 1, PaddingX: 1, PaddingY: 1}
+	valid.checkShapes(1)
+
+	bad := []Conv2D{
+		// Truncating division would give one output column.
+		{InputWidth: 2, InputHeight: 3, InputDepth: 1, FilterWidth: 3, FilterHeight: 3,
+			FilterCount: 1, StrideX: 2},
+		{InputWidth: 3, InputHeight: 2, InputDepth: 1, FilterWidth: 3, FilterHeight: 3,
+			FilterCount: 1, StrideY: 2},
+		{InputWidth: 0, InputHeight: 3, InputDepth: 1, FilterWidth: 3, FilterHeight: 3,
+			FilterCount: 1},
+	}
+	for i, conv := range bad {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("case %d: expected panic", i)
+				}
+			}()
+			conv.checkShapes(1)
+		}()
+	}
+}
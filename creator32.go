@@ -64,8 +64,8 @@ func (c *Creator32) Concat(v ...anyvec.Vector) anyvec.Vector {
 		bufferID: new(int),
 	}
 
-	c.run(func() error {
-		buf, err := cuda.AllocBuffer(c.Handle.allocator, uintptr(totalLen)*4)
+	res.run([]*vector32{res}, func() error {
+		buf, err := c.Handle.allocBuffer(SiteConcat, uintptr(totalLen)*4)
 		if err != nil {
 			return err
 		}
@@ -115,8 +115,19 @@ func (c *Creator32) Float64Slice(n anyvec.NumericList) []float64 {
 }
 
 func (c *Creator32) run(f func() error) <-chan error {
+	var stack []uintptr
+	if c.Handle.stats.Tracing() {
+		stack = callerStack(1)
+	}
+	f = c.Handle.traceOp(f)
 	return c.Handle.context.Run(func() error {
-		if err := f(); err != nil {
+		if stack != nil {
+			c.Handle.stats.SetStack(stack)
+			defer c.Handle.stats.SetStack(nil)
+		}
+		err := f()
+		c.Handle.releaseScratch()
+		if err != nil {
 			panic(err)
 		}
 		return nil
@@ -3,6 +3,8 @@ package cudavec
 
 import (
 	"errors"
+	"sync"
+	"sync/atomic"
 
 	"github.com/unixpickle/cuda"
 	"github.com/unixpickle/cuda/cublas"
@@ -20,13 +22,33 @@ const maxCudaStreams = 32
 type Handle struct {
 	context   *cuda.Context
 	allocator cuda.Allocator
+	stats     *statsAllocator
 
 	gen  *curand.Generator
 	blas *cublas.Handle
 
-	kernels32 *cuda.Module
+	kernels32  *cuda.Module
+	maxGrid    uint
+	deviceName string
+
+	tuneCache     *TuneCache
+	tuneBenchmark bool
 
 	streams []*cuda.Stream
+
+	scratch scratchArena
+
+	profile *Profile
+
+	tracer atomic.Value
+
+	sanitizer int32
+
+	finiteLock    sync.Mutex
+	finiteCheck   bool
+	finiteHandler func(err *NonFiniteError)
+
+	mapperCache mapperCache
 }
 
 // NewHandleDefault creates a handle with the default CUDA
@@ -41,6 +63,11 @@ func NewHandleDefault() (*Handle, error) {
 // If the context is nil, a new one is created.
 //
 // If the allocator is nil, a new one is created.
+//
+// The kernels are loaded from the embedded PTX for the
+// newest architecture that the context's device supports,
+// falling back to older architectures if the driver cannot
+// load it.
 func NewHandle(ctx *cuda.Context, all cuda.Allocator) (h *Handle, err error) {
 	defer essentials.AddCtxTo("create Handle", &err)
 	if ctx == nil {
@@ -48,7 +75,7 @@ func NewHandle(ctx *cuda.Context, all cuda.Allocator) (h *Handle, err error) {
 		if err != nil {
 			return nil, err
 		}
-		if err != nil {
+		if len(devs) == 0 {
 			return nil, errors.New("no CUDA devices")
 		}
 		ctx, err = cuda.NewContext(devs[0], -1)
@@ -57,8 +84,19 @@ func NewHandle(ctx *cuda.Context, all cuda.Allocator) (h *Handle, err error) {
 		}
 	}
 
-	h = &Handle{context: ctx, allocator: all}
+	h = &Handle{
+		context:   ctx,
+		allocator: all,
+	}
+	h.mapperCache.SetLimit(DefaultMapperCacheBytes)
 	err = <-ctx.Run(func() (err error) {
+		device, err := currentDevice()
+		if err != nil {
+			return err
+		}
+		h.maxGrid = device.MaxGrid
+		h.deviceName = device.Name
+
 		h.gen, err = curand.NewGenerator(ctx, curand.PseudoDefault)
 		if err != nil {
 			return err
@@ -73,7 +111,7 @@ func NewHandle(ctx *cuda.Context, all cuda.Allocator) (h *Handle, err error) {
 			return err
 		}
 
-		h.kernels32, err = cuda.NewModule(ctx, kernels32PTX)
+		h.kernels32, err = loadKernels(ctx, device.Capability)
 		if err != nil {
 			return err
 		}
@@ -85,6 +123,9 @@ func NewHandle(ctx *cuda.Context, all cuda.Allocator) (h *Handle, err error) {
 			}
 			h.allocator = cuda.GCAllocator(h.allocator, 0)
 		}
+		h.stats = newStatsAllocator(h.allocator)
+		h.stats.trim = h.scratch.clear
+		h.allocator = h.stats
 		return nil
 	})
 
@@ -0,0 +1,65 @@
+package cudavec
+
+/*
+#cgo LDFLAGS: -lcuda
+#include <cuda.h>
+*/
+import "C"
+
+// deviceNameLen is the size of the buffer for a device's
+// name, including the NUL terminator.
+const deviceNameLen = 256
+
+// A deviceInfo describes the device that a context runs
+// on.
+type deviceInfo struct {
+	Name       string
+	Capability computeCapability
+
+	// MaxGrid is the largest x dimension of a grid.
+	MaxGrid uint
+}
+
+// currentDevice queries the device of the current
+// context.
+//
+// It must be called on the context goroutine, so that it
+// sees the Handle's context rather than some other
+// context or device.
+func currentDevice() (*deviceInfo, error) {
+	var dev C.CUdevice
+	if err := driverError("cuCtxGetDevice", C.cuCtxGetDevice(&dev)); err != nil {
+		return nil, err
+	}
+	attr := func(attr C.CUdevice_attribute) (int, error) {
+		var res C.int
+		err := driverError("cuDeviceGetAttribute", C.cuDeviceGetAttribute(&res, attr, dev))
+		return int(res), err
+	}
+	major, err := attr(C.CU_DEVICE_ATTRIBUTE_COMPUTE_CAPABILITY_MAJOR)
+	if err != nil {
+		return nil, err
+	}
+	minor, err := attr(C.CU_DEVICE_ATTRIBUTE_COMPUTE_CAPABILITY_MINOR)
+	if err != nil {
+		return nil, err
+	}
+	maxGrid, err := attr(C.CU_DEVICE_ATTRIBUTE_MAX_GRID_DIM_X)
+	if err != nil {
+		return nil, err
+	}
+	var name [deviceNameLen]C.char
+	err = driverError("cuDeviceGetName", C.cuDeviceGetName(&name[0], deviceNameLen, dev))
+	if err != nil {
+		return nil, err
+	}
+	res := &deviceInfo{
+		Name:       C.GoString(&name[0]),
+		Capability: computeCapability{Major: major, Minor: minor},
+		MaxGrid:    uint(maxGrid),
+	}
+	if res.MaxGrid < defaultMaxGrid {
+		res.MaxGrid = defaultMaxGrid
+	}
+	return res, nil
+}
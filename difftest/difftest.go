@@ -0,0 +1,349 @@
+// Package difftest compares anyvec implementations by
+// running random sequences of operations on each of them.
+//
+// When two implementations disagree, the failing sequence
+// is shrunk to a minimal reproducer.
+//
+// There is no host emulator for the kernels of cudavec, so
+// comparing its Creator32 requires a CUDA device.
+// Without one, only the harness itself is tested, by
+// comparing anyvec32 with itself.
+package difftest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// Config controls the sequences used for testing and the
+// tolerance for comparisons.
+//
+// Zero fields are replaced by defaults.
+type Config struct {
+	// VectorSize is the length of each vector.
+	VectorSize int
+
+	// NumVectors is the number of vectors that operations
+	// read and write.
+	// It must be at least 3.
+	NumVectors int
+
+	// NumOps is the number of operations per sequence.
+	NumOps int
+
+	// MaxULP is the maximum difference between two values
+	// in units in the last place.
+	MaxULP uint32
+
+	// AbsTol is an absolute tolerance, which is needed
+	// for results that should be close to zero.
+	AbsTol float64
+}
+
+// A Sequence is a reproducible list of operations.
+type Sequence struct {
+	// Init stores the initial contents of each vector.
+	Init [][]float64
+
+	Ops []*Op
+}
+
+// String formats the sequence for use in bug reports.
+func (s *Sequence) String() string {
+	var lines []string
+	for i, vec := range s.Init {
+		lines = append(lines, fmt.Sprintf("v%d = %v", i, vec))
+	}
+	for i, op := range s.Ops {
+		lines = append(lines, fmt.Sprintf("%d: %s", i, op))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// A Mismatch is an error describing a value which
+// differed between implementations.
+type Mismatch struct {
+	// Step is the index of the operation whose result
+	// differed, or -1 if the mismatch is in the final
+	// contents of a vector.
+	Step int
+
+	// Vector is the index of the vector that differed,
+	// or -1 if Step is not -1.
+	Vector int
+
+	Index    int
+	Actual   float64
+	Expected float64
+}
+
+// Error describes the mismatch.
+func (m *Mismatch) Error() string {
+	if m.Step >= 0 {
+		return fmt.Sprintf("result %d of op %d: expected %v but got %v", m.Index,
+			m.Step, m.Expected, m.Actual)
+	}
+	return fmt.Sprintf("vector %d component %d: expected %v but got %v", m.Vector,
+		m.Index, m.Expected, m.Actual)
+}
+
+// A Failure is a shrunk sequence that produces different
+// results on two implementations.
+type Failure struct {
+	Sequence *Sequence
+	Err      error
+}
+
+// Error describes the failure and its sequence.
+func (f *Failure) Error() string {
+	return fmt.Sprintf("%s\nsequence:\n%s", f.Err, f.Sequence)
+}
+
+// RandomSequence generates a random sequence.
+func (c *Config) RandomSequence(r *rand.Rand) *Sequence {
+	c = c.withDefaults()
+	res := &Sequence{}
+	for i := 0; i < c.NumVectors; i++ {
+		vec := make([]float64, c.VectorSize)
+		for j := range vec {
+			vec[j] = r.NormFloat64()
+		}
+		res.Init = append(res.Init, vec)
+	}
+	for i := 0; i < c.NumOps; i++ {
+		res.Ops = append(res.Ops, randomOp(r, c.VectorSize, c.NumVectors))
+	}
+	return res
+}
+
+// Check runs a random sequence on both creators.
+//
+// It returns nil if the results match, or a shrunk
+// sequence otherwise.
+func (c *Config) Check(test, ref anyvec.Creator, r *rand.Rand) *Failure {
+	seq := c.RandomSequence(r)
+	if c.Compare(test, ref, seq) == nil {
+		return nil
+	}
+	seq = Shrink(seq, func(s *Sequence) bool {
+		return c.Compare(test, ref, s) != nil
+	})
+	return &Failure{Sequence: seq, Err: c.Compare(test, ref, seq)}
+}
+
+// Compare runs a sequence on both creators and compares
+// the results.
+//
+// A panic from either creator is returned as an error.
+func (c *Config) Compare(test, ref anyvec.Creator, seq *Sequence) error {
+	c = c.withDefaults()
+	actualVecs, actualObs, err := Run(test, seq)
+	if err != nil {
+		return err
+	}
+	expectedVecs, expectedObs, err := Run(ref, seq)
+	if err != nil {
+		return fmt.Errorf("reference: %s", err)
+	}
+	for step, expected := range expectedObs {
+		for i, x := range expected {
+			if !c.close(actualObs[step][i], x) {
+				return &Mismatch{Step: step, Vector: -1, Index: i,
+					Actual: actualObs[step][i], Expected: x}
+			}
+		}
+	}
+	for vecIdx, expected := range expectedVecs {
+		for i, x := range expected {
+			if !c.close(actualVecs[vecIdx][i], x) {
+				return &Mismatch{Step: -1, Vector: vecIdx, Index: i,
+					Actual: actualVecs[vecIdx][i], Expected: x}
+			}
+		}
+	}
+	return nil
+}
+
+// Run applies a sequence using the creator.
+//
+// It returns the final contents of the vectors and the
+// values observed by each operation.
+func Run(c anyvec.Creator, seq *Sequence) (vecs, obs [][]float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	var vectors []anyvec.Vector
+	for _, data := range seq.Init {
+		vectors = append(vectors, c.MakeVectorData(c.MakeNumericList(data)))
+	}
+	for _, op := range seq.Ops {
+		obs = append(obs, op.Apply(vectors))
+	}
+	for _, v := range vectors {
+		vecs = append(vecs, c.Float64Slice(v.Data()))
+	}
+	return vecs, obs, nil
+}
+
+// Shrink minimizes a failing sequence for as long as it
+// keeps failing.
+//
+// It removes operations, shortens the vectors, and
+// replaces initial values with zeros or integers.
+func Shrink(seq *Sequence, fails func(*Sequence) bool) *Sequence {
+	seq = shrinkOps(seq, fails)
+	seq = shrinkSize(seq, fails)
+	seq = shrinkOps(seq, fails)
+	return shrinkValues(seq, fails)
+}
+
+// shrinkOps removes chunks of operations, starting with
+// large chunks.
+func shrinkOps(seq *Sequence, fails func(*Sequence) bool) *Sequence {
+	ops := seq.Ops
+	withOps := func(ops []*Op) *Sequence {
+		return &Sequence{Init: seq.Init, Ops: ops}
+	}
+	for window := len(ops) / 2; window >= 1; {
+		removed := false
+		for start := 0; start+window <= len(ops); {
+			candidate := append(append([]*Op{}, ops[:start]...), ops[start+window:]...)
+			if fails(withOps(candidate)) {
+				ops = candidate
+				removed = true
+			} else {
+				start += window
+			}
+		}
+		if !removed {
+			window /= 2
+		} else if window > len(ops)/2 {
+			window = len(ops) / 2
+		}
+	}
+	return withOps(ops)
+}
+
+// shrinkSize tries to truncate the vectors to a smaller
+// power of two, dropping the operations which no longer
+// fit.
+func shrinkSize(seq *Sequence, fails func(*Sequence) bool) *Sequence {
+	if len(seq.Init) == 0 {
+		return seq
+	}
+	size := len(seq.Init[0])
+	for newSize := 1; newSize < size; newSize *= 2 {
+		candidate := &Sequence{}
+		for _, vec := range seq.Init {
+			candidate.Init = append(candidate.Init, vec[:newSize])
+		}
+		for _, op := range seq.Ops {
+			if op.fits(newSize) {
+				candidate.Ops = append(candidate.Ops, op)
+			}
+		}
+		if fails(candidate) {
+			return candidate
+		}
+	}
+	return seq
+}
+
+// shrinkValues tries to zero out each vector, and then
+// each component, before rounding the remaining
+// components to integers.
+func shrinkValues(seq *Sequence, fails func(*Sequence) bool) *Sequence {
+	init := make([][]float64, len(seq.Init))
+	for i, vec := range seq.Init {
+		init[i] = append([]float64{}, vec...)
+	}
+	res := &Sequence{Init: init, Ops: seq.Ops}
+	try := func(vec []float64, idx int, x float64) {
+		old := vec[idx]
+		if old == x {
+			return
+		}
+		vec[idx] = x
+		if !fails(res) {
+			vec[idx] = old
+		}
+	}
+	for _, vec := range init {
+		old := append([]float64{}, vec...)
+		for i := range vec {
+			vec[i] = 0
+		}
+		if !fails(res) {
+			copy(vec, old)
+		}
+	}
+	for _, vec := range init {
+		for i := range vec {
+			try(vec, i, 0)
+		}
+	}
+	for _, vec := range init {
+		for i, x := range vec {
+			try(vec, i, math.Round(x))
+		}
+	}
+	return res
+}
+
+// ULPDiff computes the number of representable float32
+// values between a and b.
+func ULPDiff(a, b float32) uint32 {
+	ordered := func(x float32) int64 {
+		bits := int64(math.Float32bits(x))
+		if bits&0x80000000 != 0 {
+			return 0x80000000 - bits
+		}
+		return bits
+	}
+	diff := ordered(a) - ordered(b)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(diff)
+}
+
+func (c *Config) close(actual, expected float64) bool {
+	if math.IsNaN(actual) || math.IsNaN(expected) {
+		return math.IsNaN(actual) && math.IsNaN(expected)
+	} else if math.Abs(actual-expected) <= c.AbsTol {
+		return true
+	}
+	return ULPDiff(float32(actual), float32(expected)) <= c.MaxULP
+}
+
+func (c *Config) withDefaults() *Config {
+	res := *c
+	if res.VectorSize == 0 {
+		res.VectorSize = 60
+	}
+	if res.NumVectors == 0 {
+		res.NumVectors = 3
+	}
+	if res.NumOps == 0 {
+		res.NumOps = 20
+	}
+	if res.MaxULP == 0 {
+		res.MaxULP = 128
+	}
+	if res.AbsTol == 0 {
+		res.AbsTol = 1e-4
+	}
+	if res.NumVectors < 3 {
+		panic("at least 3 vectors are required")
+	}
+	return &res
+}
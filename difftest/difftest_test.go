@@ -0,0 +1,172 @@
+package difftest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec32"
+)
+
+func TestULPDiff(t *testing.T) {
+	next := math.Nextafter32
+	cases := []struct {
+		a, b     float32
+		expected uint32
+	}{
+		{1, 1, 0},
+		{1, next(1, 2), 1},
+		{next(next(1, 0), 0), 1, 2},
+		{0, float32(math.Copysign(0, -1)), 0},
+		{math.SmallestNonzeroFloat32, -math.SmallestNonzeroFloat32, 2},
+		{-1, -1, 0},
+		{-1, next(-1, -2), 1},
+	}
+	for _, c := range cases {
+		if actual := ULPDiff(c.a, c.b); actual != c.expected {
+			t.Errorf("ULPDiff(%v, %v): expected %d but got %d", c.a, c.b, c.expected,
+				actual)
+		}
+	}
+	if ULPDiff(-1, 1) < 1<<30 {
+		t.Error("opposite signs should be far apart")
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := (&Config{MaxULP: 4, AbsTol: 1e-6}).withDefaults()
+	if !c.close(1e-7, -1e-7) {
+		t.Error("absolute tolerance not applied")
+	}
+	if !c.close(1000, float64(math.Nextafter32(1000, 2000))) {
+		t.Error("ULP tolerance not applied")
+	}
+	if c.close(1000, 1000.1) {
+		t.Error("values should differ")
+	}
+	if !c.close(math.NaN(), math.NaN()) || c.close(math.NaN(), 1) {
+		t.Error("bad NaN handling")
+	}
+	if !c.close(math.Inf(1), math.Inf(1)) || c.close(math.Inf(1), math.Inf(-1)) {
+		t.Error("bad infinity handling")
+	}
+}
+
+func TestShrink(t *testing.T) {
+	var ops []*Op
+	for i := 0; i < 37; i++ {
+		ops = append(ops, &Op{Kind: "Add", Dst: i})
+	}
+	seq := &Sequence{Ops: ops}
+
+	// Fail when ops 5 and 30 are both present.
+	fails := func(s *Sequence) bool {
+		var found5, found30 bool
+		for _, op := range s.Ops {
+			found5 = found5 || op.Dst == 5
+			found30 = found30 || op.Dst == 30
+		}
+		return found5 && found30
+	}
+	shrunk := Shrink(seq, fails)
+	if len(shrunk.Ops) != 2 || shrunk.Ops[0].Dst != 5 || shrunk.Ops[1].Dst != 30 {
+		t.Errorf("unexpected shrunk sequence:\n%s", shrunk)
+	}
+}
+
+func TestShrinkInit(t *testing.T) {
+	seq := &Sequence{
+		Init: [][]float64{
+			{0.5, -1.5, 2, 2.7, 0.1, 0.2, 0.3, 0.4},
+			{1, 2, 3, 4, 5, 6, 7, 8},
+			{-1, -2, -3, -4, -5, -6, -7, -8},
+		},
+		Ops: []*Op{
+			{Kind: "SliceAdd", Dst: 0, Src: 1, Start: 2, End: 8},
+			{Kind: "AddRepeated", Dst: 1, Src: 2, Chunk: 2},
+			{Kind: "AddRepeated", Dst: 1, Src: 2, Chunk: 8},
+		},
+	}
+
+	// Fail when there is a chunk of 2 and component 3 of
+	// the first vector is not zero.
+	fails := func(s *Sequence) bool {
+		var found bool
+		for _, op := range s.Ops {
+			found = found || (op.Kind == "AddRepeated" && op.Chunk == 2)
+		}
+		return found && len(s.Init[0]) > 3 && s.Init[0][3] != 0
+	}
+	shrunk := Shrink(seq, fails)
+	if len(shrunk.Ops) != 1 || shrunk.Ops[0].Chunk != 2 {
+		t.Errorf("unexpected ops:\n%s", shrunk)
+	}
+	expected := [][]float64{{0, 0, 0, 3}, {0, 0, 0, 0}, {0, 0, 0, 0}}
+	for i, vec := range expected {
+		for j, x := range vec {
+			if len(shrunk.Init[i]) != len(vec) || shrunk.Init[i][j] != x {
+				t.Fatalf("unexpected init:\n%s", shrunk)
+			}
+		}
+	}
+	if seq.Init[0][3] != 2.7 {
+		t.Error("original sequence was modified")
+	}
+}
+
+func TestOpFits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		op := randomOp(r, 12, 3)
+		if !op.fits(12) {
+			t.Errorf("op does not fit its own size: %s", op)
+		}
+	}
+	if (&Op{Kind: "SumRows", Chunk: 3}).fits(4) {
+		t.Error("chunk should not fit")
+	}
+	if (&Op{Kind: "Gemm", M: 2, N: 3, K: 2}).fits(5) {
+		t.Error("gemm should not fit")
+	}
+	if (&Op{Kind: "SliceAddSelf", Start: 0, End: 3, SrcStart: 2}).fits(4) {
+		t.Error("source slice should not fit")
+	}
+	if !(&Op{Kind: "Add"}).fits(1) {
+		t.Error("element-wise op should fit")
+	}
+}
+
+// TestCompareCPU runs the harness with anyvec32 on both
+// sides, so it is covered without a device.
+func TestCompareCPU(t *testing.T) {
+	config := &Config{VectorSize: 12, NumOps: 50}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		c := anyvec32.DefaultCreator{}
+		if failure := config.Check(c, c, r); failure != nil {
+			t.Fatal(failure)
+		}
+	}
+}
+
+func TestRandomSequence(t *testing.T) {
+	config := &Config{VectorSize: 12, NumVectors: 4, NumOps: 500}
+	seq := config.RandomSequence(rand.New(rand.NewSource(1)))
+	if len(seq.Init) != 4 || len(seq.Init[0]) != 12 || len(seq.Ops) != 500 {
+		t.Fatal("bad sequence size")
+	}
+	for _, op := range seq.Ops {
+		if op.Dst == op.Src || op.Dst == op.Src2 || op.Src == op.Src2 {
+			t.Errorf("operands are not distinct: %s", op)
+		}
+		if 12%op.Chunk != 0 {
+			t.Errorf("bad chunk: %s", op)
+		}
+		if op.Start < 0 || op.Start > op.End || op.End > 12 {
+			t.Errorf("bad slice: %s", op)
+		}
+		if op.M*op.K > 12 || op.K*op.N > 12 || op.M*op.N > 12 {
+			t.Errorf("bad gemm: %s", op)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package difftest
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// An Op is a single operation in a Sequence.
+//
+// Operations refer to vectors by index.
+// Which fields are used depends on the Kind.
+type Op struct {
+	Kind string
+
+	Dst  int
+	Src  int
+	Src2 int
+
+	Scalar float64
+
+	// Chunk is a column count or chunk size, which always
+	// divides the vector size.
+	Chunk int
+
+	// Start and End bound a slice of the vectors.
+	Start int
+	End   int
+
+	// SrcStart is the start of the source slice for
+	// SliceAddSelf, which adds a slice of Dst to another
+	// slice of Dst with the same length.
+	SrcStart int
+
+	// Gemm dimensions.
+	M, N, K        int
+	TransA, TransB bool
+}
+
+// String formats the op as Go-like code.
+func (o *Op) String() string {
+	switch o.Kind {
+	case "Add", "Sub", "Mul", "ElemMax", "Set":
+		return fmt.Sprintf("v%d.%s(v%d)", o.Dst, o.Kind, o.Src)
+	case "Scale", "AddScalar":
+		return fmt.Sprintf("v%d.%s(%v)", o.Dst, o.Kind, o.Scalar)
+	case "Tanh", "Sigmoid", "ClipPos":
+		return fmt.Sprintf("%s(v%d)", o.Kind, o.Dst)
+	case "LogSoftmax":
+		return fmt.Sprintf("LogSoftmax(v%d, %d)", o.Dst, o.Chunk)
+	case "AddRepeated", "ScaleRepeated", "AddChunks", "ScaleChunks":
+		return fmt.Sprintf("%s(v%d, v%d[:%d])", o.Kind, o.Dst, o.Src, o.Chunk)
+	case "SliceAdd":
+		return fmt.Sprintf("v%d[%d:%d].Add(v%d[%d:%d])", o.Dst, o.Start, o.End, o.Src,
+			o.Start, o.End)
+	case "SliceAddSelf":
+		return fmt.Sprintf("v%d[%d:%d].Add(v%d[%d:%d])", o.Dst, o.Start, o.End, o.Dst,
+			o.SrcStart, o.srcEnd())
+	case "Dot":
+		return fmt.Sprintf("v%d.Dot(v%d)", o.Dst, o.Src)
+	case "Sum":
+		return fmt.Sprintf("Sum(v%d)", o.Dst)
+	case "SumRows", "AddLogs":
+		return fmt.Sprintf("%s(v%d, %d)", o.Kind, o.Dst, o.Chunk)
+	case "Gemm":
+		return fmt.Sprintf("Gemm(%v, %v, %d, %d, %d, v%d, v%d, v%d)", o.TransA, o.TransB,
+			o.M, o.N, o.K, o.Src, o.Src2, o.Dst)
+	}
+	return o.Kind
+}
+
+// Apply runs the operation on a list of vectors.
+//
+// It returns the values that the operation produced
+// without storing them in a vector, if any.
+func (o *Op) Apply(vecs []anyvec.Vector) []float64 {
+	dst := vecs[o.Dst]
+	src := vecs[o.Src]
+	c := dst.Creator()
+	scalar := c.MakeNumeric(o.Scalar)
+	switch o.Kind {
+	case "Add":
+		dst.Add(src)
+	case "Sub":
+		dst.Sub(src)
+	case "Mul":
+		dst.Mul(src)
+	case "ElemMax":
+		anyvec.ElemMax(dst, src)
+	case "Set":
+		dst.Set(src)
+	case "Scale":
+		dst.Scale(scalar)
+	case "AddScalar":
+		dst.AddScalar(scalar)
+	case "Tanh":
+		anyvec.Tanh(dst)
+	case "Sigmoid":
+		anyvec.Sigmoid(dst)
+	case "ClipPos":
+		anyvec.ClipPos(dst)
+	case "LogSoftmax":
+		anyvec.LogSoftmax(dst, o.Chunk)
+	case "AddRepeated":
+		anyvec.AddRepeated(dst, src.Slice(0, o.Chunk))
+	case "ScaleRepeated":
+		anyvec.ScaleRepeated(dst, src.Slice(0, o.Chunk))
+	case "AddChunks":
+		anyvec.AddChunks(dst, src.Slice(0, o.Chunk))
+	case "ScaleChunks":
+		anyvec.ScaleChunks(dst, src.Slice(0, o.Chunk))
+	case "SliceAdd":
+		dst.Slice(o.Start, o.End).Add(src.Slice(o.Start, o.End))
+	case "SliceAddSelf":
+		dst.Slice(o.Start, o.End).Add(dst.Slice(o.SrcStart, o.srcEnd()))
+	case "Dot":
+		return []float64{c.Float64(dst.Dot(src))}
+	case "Sum":
+		return []float64{c.Float64(anyvec.Sum(dst))}
+	case "SumRows":
+		return c.Float64Slice(anyvec.SumRows(dst, o.Chunk).Data())
+	case "AddLogs":
+		return c.Float64Slice(anyvec.AddLogs(dst, o.Chunk).Data())
+	case "Gemm":
+		lda, ldb := o.K, o.N
+		if o.TransA {
+			lda = o.M
+		}
+		if o.TransB {
+			ldb = o.K
+		}
+		anyvec.Gemm(o.TransA, o.TransB, o.M, o.N, o.K, c.MakeNumeric(1),
+			src.Slice(0, o.M*o.K), lda, vecs[o.Src2].Slice(0, o.K*o.N), ldb,
+			scalar, dst.Slice(0, o.M*o.N), o.N)
+	default:
+		panic("unknown op: " + o.Kind)
+	}
+	return nil
+}
+
+// fits checks if the operation can be applied to vectors
+// of the given size.
+func (o *Op) fits(size int) bool {
+	switch o.Kind {
+	case "LogSoftmax", "AddRepeated", "ScaleRepeated", "AddChunks", "ScaleChunks",
+		"SumRows", "AddLogs":
+		return o.Chunk <= size && size%o.Chunk == 0
+	case "SliceAdd":
+		return o.End <= size
+	case "SliceAddSelf":
+		return o.End <= size && o.srcEnd() <= size
+	case "Gemm":
+		return o.M*o.K <= size && o.K*o.N <= size && o.M*o.N <= size
+	}
+	return true
+}
+
+func (o *Op) srcEnd() int {
+	return o.SrcStart + o.End - o.Start
+}
+
+var opKinds = []string{"Add", "Sub", "Mul", "ElemMax", "Set", "Scale", "AddScalar",
+	"Tanh", "Sigmoid", "ClipPos", "LogSoftmax", "AddRepeated", "ScaleRepeated",
+	"AddChunks", "ScaleChunks", "SliceAdd", "Dot", "Sum", "SumRows", "AddLogs", "Gemm"}
+
+func randomOp(r *rand.Rand, size, numVecs int) *Op {
+	perm := r.Perm(numVecs)
+	res := &Op{
+		Kind:   opKinds[r.Intn(len(opKinds))],
+		Dst:    perm[0],
+		Src:    perm[1],
+		Src2:   perm[2],
+		Scalar: r.Float64()*4 - 2,
+		Chunk:  randomDivisor(r, size),
+	}
+	res.Start = r.Intn(size + 1)
+	res.End = res.Start + r.Intn(size-res.Start+1)
+	res.TransA = r.Intn(2) == 0
+	res.TransB = r.Intn(2) == 0
+	for {
+		res.M, res.N, res.K = 1+r.Intn(size), 1+r.Intn(size), 1+r.Intn(size)
+		if res.M*res.K <= size && res.K*res.N <= size && res.M*res.N <= size {
+			break
+		}
+	}
+	return res
+}
+
+func randomDivisor(r *rand.Rand, size int) int {
+	var divisors []int
+	for i := 1; i <= size; i++ {
+		if size%i == 0 {
+			divisors = append(divisors, i)
+		}
+	}
+	return divisors[r.Intn(len(divisors))]
+}
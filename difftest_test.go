@@ -0,0 +1,28 @@
+package cudavec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/cudavec/difftest"
+)
+
+func TestDifferential(t *testing.T) {
+	handle := setupDeviceTest(t)
+	c := &Creator32{Handle: handle}
+
+	configs := []*difftest.Config{
+		{VectorSize: 60},
+		{VectorSize: 1, NumOps: 10},
+		{VectorSize: 1024, NumOps: 30, MaxULP: 1024},
+	}
+	r := rand.New(rand.NewSource(1337))
+	for _, config := range configs {
+		for i := 0; i < 20; i++ {
+			if failure := config.Check(c, anyvec32.DefaultCreator{}, r); failure != nil {
+				t.Fatalf("size %d: %s", config.VectorSize, failure)
+			}
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package cudavec
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// vectorIOChunk is the number of components transferred
+// at once by WriteVector and ReadVector.
+const vectorIOChunk = 1 << 20
+
+func init() {
+	gob.Register(&vector32{})
+}
+
+// A GobDecoder decodes gob streams like a gob.Decoder,
+// and creates every vector it decodes with a Creator32.
+//
+// Since each GobDecoder has its own Creator32, decoders
+// for different Handles can be used at the same time.
+type GobDecoder struct {
+	creator *Creator32
+	decoder *gob.Decoder
+}
+
+// NewGobDecoder creates a GobDecoder that reads from r and
+// creates vectors with c.
+//
+// Vectors decoded by a plain gob.Decoder have no
+// Creator32 and cannot be used.
+func (c *Creator32) NewGobDecoder(r io.Reader) *GobDecoder {
+	return &GobDecoder{creator: c, decoder: gob.NewDecoder(r)}
+}
+
+// Decode reads the next value from the stream and stores
+// it in e, which must be a pointer.
+//
+// Vectors are found in anyvec.Vector fields, slices,
+// arrays, maps, and pointers reachable from e.
+func (g *GobDecoder) Decode(e interface{}) error {
+	if err := g.decoder.Decode(e); err != nil {
+		return err
+	}
+	g.bind(reflect.ValueOf(e), map[uintptr]bool{})
+	return nil
+}
+
+// bind creates the device vectors for every vector in val
+// that gob decoded without a Creator32.
+func (g *GobDecoder) bind(val reflect.Value, seen map[uintptr]bool) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if val.IsNil() || seen[val.Pointer()] {
+			return
+		}
+		seen[val.Pointer()] = true
+		if vec, ok := val.Interface().(*vector32); ok {
+			if vec.creator == nil {
+				vec.creator = g.creator
+				vec.bufferID = new(int)
+				vec.size = len(vec.gobData)
+				vec.SetData(vec.gobData)
+				vec.gobData = nil
+			}
+			return
+		}
+		g.bind(val.Elem(), seen)
+	case reflect.Interface:
+		if !val.IsNil() {
+			g.bind(val.Elem(), seen)
+		}
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			// Gob only decodes exported fields.
+			if val.Type().Field(i).PkgPath == "" {
+				g.bind(val.Field(i), seen)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			g.bind(val.Index(i), seen)
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			g.bind(iter.Value(), seen)
+		}
+	}
+}
+
+// UnmarshalVector decodes a vector that was encoded with
+// MarshalBinary, GobEncode, or WriteVector.
+func (c *Creator32) UnmarshalVector(data []byte) (anyvec.Vector, error) {
+	res := &vector32{creator: c, bufferID: new(int)}
+	if err := res.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// WriteVector encodes a vector to a stream in the format
+// used by MarshalBinary.
+//
+// Unlike MarshalBinary, WriteVector copies the vector to
+// the host a chunk at a time.
+func WriteVector(w io.Writer, v anyvec.Vector) (err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("write vector: " + err.Error())
+		}
+	}()
+	var header [8]byte
+	binary.LittleEndian.PutUint64(header[:], uint64(v.Len()))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	return writeVectorData(w, v)
+}
+
+// ReadVector decodes a vector that was encoded with
+// WriteVector or MarshalBinary.
+//
+// The vector is copied to the device a chunk at a time.
+func (c *Creator32) ReadVector(r io.Reader) (vec anyvec.Vector, err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("read vector: " + err.Error())
+		}
+	}()
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint64(header[:])
+	if int(size) < 0 || uint64(int(size)) != size {
+		return nil, errors.New("vector is too large")
+	}
+	return readVectorData(r, c, int(size), float32Format)
+}
+
+// MarshalBinary encodes the vector's length and contents.
+func (v *vector32) MarshalBinary() ([]byte, error) {
+	var header [8]byte
+	binary.LittleEndian.PutUint64(header[:], uint64(v.Len()))
+	return append(header[:], encodeFloats(v.Data().([]float32))...), nil
+}
+
+// UnmarshalBinary decodes the result of MarshalBinary.
+//
+// If the decoded length matches the vector's length, the
+// contents are overwritten in place.
+// Otherwise, the vector is replaced by a new one.
+//
+// The vector must come from a Creator32; use
+// Creator32.UnmarshalVector to create a new vector.
+func (v *vector32) UnmarshalBinary(data []byte) error {
+	if v.creator == nil {
+		return errors.New("unmarshal vector: no Creator32")
+	}
+	floats, err := decodeMarshaled(data)
+	if err != nil {
+		return err
+	}
+	if len(floats) != v.Len() || v.bufferID == nil {
+		v.runSync(func() error {
+			v.size = len(floats)
+			v.bufferID = new(int)
+			v.start = 0
+			v.buffer = nil
+			return nil
+		})
+	}
+	v.SetData(floats)
+	return nil
+}
+
+// GobEncode is equivalent to MarshalBinary.
+func (v *vector32) GobEncode() ([]byte, error) {
+	return v.MarshalBinary()
+}
+
+// GobDecode is equivalent to UnmarshalBinary, except that
+// a vector without a Creator32 keeps its contents on the
+// host until a GobDecoder binds it.
+func (v *vector32) GobDecode(data []byte) error {
+	if v.creator != nil {
+		return v.UnmarshalBinary(data)
+	}
+	floats, err := decodeMarshaled(data)
+	if err != nil {
+		return err
+	}
+	v.gobData = floats
+	return nil
+}
+
+// decodeMarshaled decodes the contents of a vector from
+// the result of MarshalBinary.
+func decodeMarshaled(data []byte) ([]float32, error) {
+	if len(data) < 8 {
+		return nil, errors.New("unmarshal vector: data too short")
+	}
+	size := binary.LittleEndian.Uint64(data)
+	if uint64(len(data)-8)/4 != size || (len(data)-8)%4 != 0 {
+		return nil, errors.New("unmarshal vector: invalid data size")
+	}
+	return decodeFloats(data[8:]), nil
+}
+
+// writeVectorData writes the little-endian contents of a
+// vector, copying it to the host a chunk at a time.
+func writeVectorData(w io.Writer, v anyvec.Vector) error {
+	for i := 0; i < v.Len(); i += vectorIOChunk {
+		end := i + vectorIOChunk
+		if end > v.Len() {
+			end = v.Len()
+		}
+		data := v.Slice(i, end).Data().([]float32)
+		if _, err := w.Write(encodeFloats(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVectorData reads a vector of the given size,
+// copying it to the device a chunk at a time.
+//
+// If the reader can report how much input is left, the
+// size is checked against it before anything is allocated.
+// Otherwise, the device buffer is only allocated once the
+// first chunk has been read.
+func readVectorData(r io.Reader, c *Creator32, size int,
+	format *floatFormat) (anyvec.Vector, error) {
+	if err := checkRemaining(r, size, format); err != nil {
+		return nil, err
+	}
+	res := c.MakeVector(size).(*vector32)
+	chunkSize := vectorIOChunk
+	if size < chunkSize {
+		chunkSize = size
+	}
+	buf := make([]byte, chunkSize*format.size)
+	for i := 0; i < size; i += chunkSize {
+		end := i + chunkSize
+		if end > size {
+			end = size
+		}
+		chunk := buf[:(end-i)*format.size]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			// Allocate here rather than in SetData, so that an
+			// allocation failure is returned instead of causing
+			// a panic.
+			err := <-c.Handle.context.Run(func() error {
+				return res.lazyInit(false)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		res.Slice(i, end).SetData(format.decode(chunk))
+	}
+	return res, nil
+}
+
+// readHostData is like readVectorData, but it reads the
+// values into host memory.
+//
+// Without a size check, the buffer grows a chunk at a time
+// as the data arrives, so a bad size cannot force a large
+// allocation.
+func readHostData(r io.Reader, size int, format *floatFormat) ([]float32, error) {
+	if err := checkRemaining(r, size, format); err != nil {
+		return nil, err
+	}
+	var res []float32
+	for i := 0; i < size; i += vectorIOChunk {
+		end := i + vectorIOChunk
+		if end > size {
+			end = size
+		}
+		chunk := make([]byte, (end-i)*format.size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		res = append(res, format.decode(chunk)...)
+	}
+	return res, nil
+}
+
+// checkRemaining checks that a reader has enough input
+// left for size values, if its remaining input is known.
+func checkRemaining(r io.Reader, size int, format *floatFormat) error {
+	remaining, ok, err := inputRemaining(r)
+	if err != nil {
+		return err
+	} else if ok && int64(size) > remaining/int64(format.size) {
+		return fmt.Errorf("vector of size %d needs %d bytes but only %d remain",
+			size, int64(size)*int64(format.size), remaining)
+	}
+	return nil
+}
+
+// inputRemaining finds the number of bytes left in a
+// reader, if it is an in-memory reader or a seeker.
+func inputRemaining(r io.Reader) (int64, bool, error) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true, nil
+	case io.Seeker:
+		cur, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			// Some seekers, such as pipes, cannot seek at all.
+			return 0, false, nil
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false, err
+		}
+		if _, err := r.Seek(cur, io.SeekStart); err != nil {
+			return 0, false, err
+		}
+		return end - cur, true, nil
+	}
+	return 0, false, nil
+}
+
+func encodeFloats(data []float32) []byte {
+	res := make([]byte, 4*len(data))
+	for i, x := range data {
+		binary.LittleEndian.PutUint32(res[4*i:], math.Float32bits(x))
+	}
+	return res
+}
+
+func decodeFloats(data []byte) []float32 {
+	res := make([]float32, len(data)/4)
+	for i := range res {
+		res[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return res
+}
@@ -0,0 +1,144 @@
+package cudavec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/unixpickle/anyvec"
+)
+
+func TestVectorMarshal(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	vec := c.MakeVectorData([]float32{1, -2.5, 3, 7})
+	data, err := vec.(*vector32).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := c.UnmarshalVector(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "unmarshal", decoded.Data().([]float32), vec.Data().([]float32))
+
+	// Decoding into a slice should write in place.
+	parent := c.MakeVector(6)
+	if err := parent.Slice(1, 5).(*vector32).UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "in place", parent.Data().([]float32), []float32{0, 1, -2.5, 3, 7, 0})
+
+	if _, err := c.UnmarshalVector(data[:len(data)-2]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
+
+func TestVectorGob(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	type layer struct {
+		Biases anyvec.Vector
+	}
+	type checkpoint struct {
+		Weights anyvec.Vector
+		Layers  []*layer
+		Named   map[string]anyvec.Vector
+	}
+	input := checkpoint{
+		Weights: c.MakeVectorData([]float32{1, 2, 3}),
+		Layers:  []*layer{{Biases: c.MakeVectorData([]float32{-1})}},
+		Named:   map[string]anyvec.Vector{"x": c.MakeVectorData([]float32{4, 5})},
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&input); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// Decoders for different Creators must not interfere.
+	for _, creator := range []*Creator32{c, {Handle: handle}} {
+		var output checkpoint
+		err := creator.NewGobDecoder(bytes.NewReader(encoded)).Decode(&output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, vec := range []anyvec.Vector{output.Weights, output.Layers[0].Biases,
+			output.Named["x"]} {
+			if vec.Creator() != creator {
+				t.Error("decoded vector has the wrong creator")
+			}
+		}
+		assertClose(t, "weights", output.Weights.Data().([]float32), []float32{1, 2, 3})
+		assertClose(t, "biases", output.Layers[0].Biases.Data().([]float32),
+			[]float32{-1})
+		assertClose(t, "named", output.Named["x"].Data().([]float32), []float32{4, 5})
+	}
+}
+
+func TestVectorStream(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	// Use a vector which spans multiple chunks.
+	data := randomSlice(vectorIOChunk*2 + 3)
+	var buf bytes.Buffer
+	if err := WriteVector(&buf, c.MakeVectorData(data)); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := c.ReadVector(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "stream", decoded.Data().([]float32), data)
+
+	if _, err := c.ReadVector(bytes.NewReader([]byte{1, 0, 0, 0, 0, 0, 0, 0})); err == nil {
+		t.Error("expected error for missing data")
+	}
+
+	// A huge size must be rejected before it is allocated,
+	// whether or not the reader knows how much is left.
+	huge := []byte{0, 0, 0, 0, 0, 1, 0, 0, 1, 2, 3, 4}
+	before := handle.MemStats().Allocs
+	if _, err := c.ReadVector(bytes.NewReader(huge)); err == nil {
+		t.Error("expected error for huge size")
+	}
+	if _, err := c.ReadVector(io.MultiReader(bytes.NewReader(huge))); err == nil {
+		t.Error("expected error for huge size in stream")
+	}
+	if allocs := handle.MemStats().Allocs; allocs != before {
+		t.Errorf("huge vector caused %d allocations", allocs-before)
+	}
+}
+
+func TestInputRemaining(t *testing.T) {
+	f, err := ioutil.TempFile("", "cudavec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(3, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []io.Reader{bytes.NewReader(make([]byte, 7)), f} {
+		remaining, ok, err := inputRemaining(r)
+		if err != nil || !ok || remaining != 7 {
+			t.Errorf("%T: got %d, %v, %v", r, remaining, ok, err)
+		}
+	}
+	if pos, _ := f.Seek(0, io.SeekCurrent); pos != 3 {
+		t.Errorf("file position moved to %d", pos)
+	}
+	if _, ok, _ := inputRemaining(io.MultiReader()); ok {
+		t.Error("unexpected remaining size for stream")
+	}
+}
@@ -0,0 +1,75 @@
+package cudavec
+
+/*
+#cgo LDFLAGS: -lcuda
+#include <cuda.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unixpickle/cuda"
+)
+
+// A timingEvent is a CUDA event used to time work on the
+// device.
+//
+// Events must only be used on the context goroutine.
+type timingEvent struct {
+	event C.CUevent
+}
+
+func newTimingEvent() (*timingEvent, error) {
+	res := &timingEvent{}
+	if err := driverError("cuEventCreate", C.cuEventCreate(&res.event,
+		C.CU_EVENT_DEFAULT)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Record records the event on a stream, so that it
+// completes once all of the work queued on the stream has
+// finished.
+//
+// A nil stream is the default stream.
+func (t *timingEvent) Record(stream *cuda.Stream) error {
+	var s C.CUstream
+	if stream != nil {
+		s = C.CUstream(stream.Pointer())
+	}
+	return driverError("cuEventRecord", C.cuEventRecord(t.event, s))
+}
+
+// Since waits for the event to complete and returns the
+// time elapsed on the device since start completed.
+func (t *timingEvent) Since(start *timingEvent) (time.Duration, error) {
+	if err := driverError("cuEventSynchronize", C.cuEventSynchronize(t.event)); err != nil {
+		return 0, err
+	}
+	var ms C.float
+	err := driverError("cuEventElapsedTime", C.cuEventElapsedTime(&ms, start.event,
+		t.event))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(float64(ms) * float64(time.Millisecond)), nil
+}
+
+// Destroy frees the event.
+func (t *timingEvent) Destroy() {
+	C.cuEventDestroy(t.event)
+}
+
+func driverError(name string, res C.CUresult) error {
+	if res == C.CUDA_SUCCESS {
+		return nil
+	}
+	var str *C.char
+	if C.cuGetErrorString(res, &str) != C.CUDA_SUCCESS || str == nil {
+		return fmt.Errorf("%s: CUDA error %d", name, int(res))
+	}
+	return fmt.Errorf("%s: %s", name, C.GoString(str))
+}
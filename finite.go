@@ -0,0 +1,115 @@
+package cudavec
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/cuda"
+)
+
+// A NonFiniteError reports an operation which produced
+// NaN or infinite values.
+type NonFiniteError struct {
+	// Op is the function that queued the operation.
+	Op string
+
+	// Count is the number of non-finite components in the
+	// vectors written by the operation.
+	Count int
+
+	// Stack is the stack trace of the code that queued
+	// the operation.
+	Stack string
+}
+
+// Error returns a description of the error, including
+// the stack trace.
+func (n *NonFiniteError) Error() string {
+	return fmt.Sprintf("%s produced %d non-finite values\n%s", n.Op, n.Count, n.Stack)
+}
+
+// SetFiniteCheck enables or disables checking for NaN and
+// infinite values.
+//
+// When enabled, the vectors that each operation writes
+// are checked after the operation runs.
+// If it contains non-finite values, handler is called
+// with a description of the operation.
+// If handler is nil, the check panics with the error.
+//
+// The handler is called on the context goroutine, so it
+// must not wait for any vector operations.
+// Since every operation is followed by a device-to-host
+// read, this mode is only meant for debugging.
+func (h *Handle) SetFiniteCheck(enabled bool, handler func(err *NonFiniteError)) {
+	h.finiteLock.Lock()
+	defer h.finiteLock.Unlock()
+	h.finiteCheck = enabled
+	h.finiteHandler = handler
+	if h.finiteHandler == nil {
+		h.finiteHandler = func(err *NonFiniteError) {
+			panic(err)
+		}
+	}
+}
+
+// checkFinite wraps an operation so that the vectors it
+// writes are checked for non-finite values afterwards.
+//
+// It must be called from the goroutine that is queueing
+// the operation.
+// If the check is disabled, f is returned unchanged.
+func (h *Handle) checkFinite(writes []*vector32, f func() error) func() error {
+	h.finiteLock.Lock()
+	enabled, handler := h.finiteCheck, h.finiteHandler
+	h.finiteLock.Unlock()
+	if !enabled || len(writes) == 0 {
+		return f
+	}
+	op := callerName()
+	stack := callerStack(1)
+	return func() error {
+		if err := f(); err != nil {
+			return err
+		}
+		var total int
+		for _, v := range writes {
+			count, err := h.countNonFinite(v)
+			if err != nil {
+				return err
+			}
+			total += count
+		}
+		if total != 0 {
+			handler(&NonFiniteError{
+				Op:    op,
+				Count: total,
+				Stack: formatStack(stack),
+			})
+		}
+		return nil
+	}
+}
+
+// countNonFinite counts the NaN and infinite components
+// of a vector.
+func (h *Handle) countNonFinite(v *vector32) (int, error) {
+	if v.buffer == nil || v.Len() == 0 {
+		return 0, nil
+	}
+	count, err := h.scratchBuffer(SiteTemp, 4)
+	if err != nil {
+		return 0, err
+	}
+	if err := cuda.ClearBuffer(count); err != nil {
+		return 0, err
+	}
+	err = h.launchFlat("countNonFinite", v.Len(), count, v.buffer, int64(v.Len()))
+	if err != nil {
+		return 0, err
+	}
+	counts := make([]int32, 1)
+	if err := cuda.ReadBuffer(counts, count); err != nil {
+		return 0, err
+	}
+	return int(counts[0]), nil
+}
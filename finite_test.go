@@ -0,0 +1,76 @@
+package cudavec
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFiniteCheck(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	var errs []*NonFiniteError
+	handle.SetFiniteCheck(true, func(err *NonFiniteError) {
+		errs = append(errs, err)
+	})
+	defer handle.SetFiniteCheck(false, nil)
+
+	vec := c.MakeVectorData([]float32{1, 2, -3, 0})
+	vec.Scale(float32(2))
+	vec.Data()
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	vec.(*vector32).Pow(float32(0.5))
+	vec.Data()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error but got %d", len(errs))
+	}
+	if !strings.HasSuffix(errs[0].Op, ".Pow") || errs[0].Count != 1 {
+		t.Errorf("unexpected error: %s", errs[0])
+	}
+	if !strings.Contains(errs[0].Stack, "TestFiniteCheck") {
+		t.Errorf("stack does not contain caller: %s", errs[0].Stack)
+	}
+
+	vec = c.MakeVectorData([]float32{1, float32(math.Inf(1))})
+	vec.AddScalar(float32(1))
+	vec.Data()
+	if len(errs) != 2 || errs[1].Count != 1 {
+		t.Errorf("infinity not detected: %v", errs)
+	}
+
+	// Operations which only read a vector must not report
+	// its values.
+	errs = nil
+	vec.Slice(0, 1).Data()
+	vec.(*vector32).SumRows(2).Data()
+	vec.(*vector32).AddLogs(1).Data()
+	if len(errs) != 2 {
+		t.Fatalf("expected errors from SumRows and AddLogs but got %v", errs)
+	}
+	for i, suffix := range []string{".SumRows", ".AddLogs"} {
+		if !strings.HasSuffix(errs[i].Op, suffix) {
+			t.Errorf("error %d: expected %s but got %s", i, suffix, errs[i].Op)
+		}
+	}
+
+	// Operations which write to another vector must check it.
+	errs = nil
+	out := c.MakeVector(1)
+	c.MakeMapper(2, []int{1}).Map(vec, out)
+	out.Data()
+	if len(errs) != 1 || !strings.HasSuffix(errs[0].Op, ".Map") {
+		t.Errorf("unexpected errors from Map: %v", errs)
+	}
+}
+
+func TestNonFiniteError(t *testing.T) {
+	err := &NonFiniteError{Op: "cudavec.(*vector32).Exp", Count: 3, Stack: "\tmain.main\n"}
+	expected := "cudavec.(*vector32).Exp produced 3 non-finite values\n\tmain.main\n"
+	if err.Error() != expected {
+		t.Errorf("unexpected message: %q", err.Error())
+	}
+}
@@ -0,0 +1,217 @@
+package cudavec
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/cudavec/difftest"
+)
+
+// fuzzMaxSize bounds the vector sizes used by the fuzz
+// targets.
+const fuzzMaxSize = 1 << 12
+
+// fuzzMaxChunk bounds the chunk sizes used by the
+// AddLogs fuzz target, which reduces each chunk in a
+// single pass.
+const fuzzMaxChunk = 1 << 19
+
+func fuzzSize(x uint16) int {
+	return int(x) % (fuzzMaxSize + 1)
+}
+
+// fuzzCompare runs a sequence on the device and on
+// anyvec32 and fails if the results differ by more than
+// maxULP.
+func fuzzCompare(t *testing.T, maxULP uint32, seq *difftest.Sequence) {
+	handle := setupDeviceTest(t)
+	c := &Creator32{Handle: handle}
+	config := &difftest.Config{MaxULP: maxULP}
+	if err := config.Compare(c, anyvec32.DefaultCreator{}, seq); err != nil {
+		t.Fatalf("%s\nsequence:\n%s", err, seq)
+	}
+}
+
+func fuzzSequence(seed int64, size int, ops ...*difftest.Op) *difftest.Sequence {
+	r := rand.New(rand.NewSource(seed))
+	seq := &difftest.Sequence{Ops: ops}
+	for i := 0; i < 3; i++ {
+		vec := make([]float64, size)
+		for j := range vec {
+			vec[j] = r.NormFloat64()
+		}
+		seq.Init = append(seq.Init, vec)
+	}
+	return seq
+}
+
+func FuzzSlice(f *testing.F) {
+	f.Add(uint16(10), uint16(0), uint16(10), int64(0))
+	f.Add(uint16(0), uint16(0), uint16(0), int64(1))
+	f.Add(uint16(129), uint16(127), uint16(129), int64(2))
+	f.Fuzz(func(t *testing.T, size, start, end uint16, seed int64) {
+		n := fuzzSize(size)
+		s := int(start) % (n + 1)
+		e := s + int(end)%(n-s+1)
+		fuzzCompare(t, 256, fuzzSequence(seed, n,
+			&difftest.Op{Kind: "SliceAdd", Dst: 0, Src: 1, Start: s, End: e},
+			&difftest.Op{Kind: "Sum", Dst: 0, Src: 1}))
+	})
+}
+
+func FuzzSliceOverlap(f *testing.F) {
+	f.Add(uint16(10), uint16(0), uint16(5), uint16(5), int64(0))
+	f.Add(uint16(10), uint16(2), uint16(4), uint16(5), int64(1))
+	f.Add(uint16(10), uint16(3), uint16(3), uint16(0), int64(2))
+	f.Add(uint16(1000), uint16(0), uint16(1), uint16(999), int64(3))
+	f.Fuzz(func(t *testing.T, size, start, srcStart, length uint16, seed int64) {
+		n := fuzzSize(size)
+		l := int(length) % (n + 1)
+		op := &difftest.Op{
+			Kind:     "SliceAddSelf",
+			Dst:      0,
+			Start:    int(start) % (n - l + 1),
+			SrcStart: int(srcStart) % (n - l + 1),
+		}
+		op.End = op.Start + l
+		seq := fuzzSequence(seed, n, op)
+		if l > 0 && op.Start < op.SrcStart+l && op.SrcStart < op.End {
+			handle := setupDeviceTest(t)
+			_, _, err := difftest.Run(&Creator32{Handle: handle}, seq)
+			if err == nil || !strings.Contains(err.Error(), "invalid overlap") {
+				t.Fatalf("expected overlap panic but got %v\nsequence:\n%s", err, seq)
+			}
+			return
+		}
+		fuzzCompare(t, 256, seq)
+	})
+}
+
+func FuzzChunkedOps(f *testing.F) {
+	f.Add(uint16(12), uint16(3), uint8(0), int64(0))
+	f.Add(uint16(2048), uint16(2048), uint8(5), int64(1))
+	f.Add(uint16(1), uint16(1), uint8(6), int64(2))
+	f.Add(uint16(3000), uint16(1000), uint8(7), int64(3))
+	kinds := []string{"AddRepeated", "ScaleRepeated", "AddChunks", "ScaleChunks",
+		"LogSoftmax", "AddLogs", "SumRows", "Sum"}
+	f.Fuzz(func(t *testing.T, size, chunk uint16, kind uint8, seed int64) {
+		n := fuzzSize(size)
+		if n == 0 {
+			n = 1
+		}
+		// Find the largest divisor that is at most chunk.
+		c := int(chunk)%n + 1
+		for n%c != 0 {
+			c--
+		}
+		op := &difftest.Op{Kind: kinds[int(kind)%len(kinds)], Dst: 0, Src: 1, Chunk: c}
+		fuzzCompare(t, 256, fuzzSequence(seed, n, op))
+	})
+}
+
+func FuzzAddLogsLargeChunks(f *testing.F) {
+	f.Add(uint32(4097), uint8(1), int64(0))
+	f.Add(uint32(fuzzMaxChunk), uint8(0), int64(1))
+	f.Add(uint32(65535), uint8(3), int64(2))
+	f.Fuzz(func(t *testing.T, chunk uint32, rows uint8, seed int64) {
+		c := int(chunk)%fuzzMaxChunk + 1
+		op := &difftest.Op{Kind: "AddLogs", Dst: 0, Chunk: c}
+
+		// Sums of many terms round differently depending
+		// on the order of the reduction.
+		fuzzCompare(t, 1024, fuzzSequence(seed, c*(int(rows)%4+1), op))
+	})
+}
+
+func FuzzGemm(f *testing.F) {
+	f.Add(uint8(1), uint8(1), uint8(1), false, false, int64(0))
+	f.Add(uint8(7), uint8(3), uint8(5), true, false, int64(1))
+	f.Add(uint8(16), uint8(33), uint8(2), false, true, int64(2))
+	f.Fuzz(func(t *testing.T, m, n, k uint8, transA, transB bool, seed int64) {
+		op := &difftest.Op{
+			Kind:   "Gemm",
+			Dst:    0,
+			Src:    1,
+			Src2:   2,
+			Scalar: 0.5,
+			M:      int(m)%64 + 1,
+			N:      int(n)%64 + 1,
+			K:      int(k)%64 + 1,
+			TransA: transA,
+			TransB: transB,
+		}
+		size := op.M * op.K
+		if op.K*op.N > size {
+			size = op.K * op.N
+		}
+		if op.M*op.N > size {
+			size = op.M * op.N
+		}
+		fuzzCompare(t, 256, fuzzSequence(seed, size, op))
+	})
+}
+
+func FuzzMapper(f *testing.F) {
+	f.Add(uint16(4), []byte{0, 1, 2, 3, 3})
+	f.Add(uint16(1), []byte{})
+	f.Add(uint16(300), []byte{255, 0, 17, 17, 17})
+	f.Fuzz(func(t *testing.T, inSize uint16, tableData []byte) {
+		handle := setupDeviceTest(t)
+		c := &Creator32{Handle: handle}
+		ref := anyvec32.DefaultCreator{}
+
+		in := fuzzSize(inSize) + 1
+		table := make([]int, len(tableData))
+		for i, x := range tableData {
+			table[i] = int(x) % in
+		}
+		input := randomSlice(in)
+		outGrad := randomSlice(len(table))
+
+		check := func(creator anyvec.Creator) (mapped, transposed []float32) {
+			m := creator.MakeMapper(in, table)
+			out := creator.MakeVector(len(table))
+			m.Map(creator.MakeVectorData(input), out)
+			inGrad := creator.MakeVectorData(input)
+			m.MapTranspose(creator.MakeVectorData(outGrad), inGrad)
+			return out.Data().([]float32), inGrad.Data().([]float32)
+		}
+		actualMap, actualTrans := check(c)
+		expectedMap, expectedTrans := check(ref)
+		assertClose(t, "map", actualMap, expectedMap)
+		assertClose(t, "transpose", actualTrans, expectedTrans)
+
+		m := c.MakeMapper(in, table).(*mapper32)
+		for i, x := range m.Table() {
+			if x != table[i] {
+				t.Fatalf("table entry %d: expected %d but got %d", i, table[i], x)
+			}
+		}
+	})
+}
+
+func FuzzRandNormal(f *testing.F) {
+	f.Add(uint16(1))
+	f.Add(uint16(2))
+	f.Add(uint16(1023))
+	f.Fuzz(func(t *testing.T, size uint16) {
+		handle := setupDeviceTest(t)
+		c := &Creator32{Handle: handle}
+		n := fuzzSize(size)
+		vec := c.MakeVector(n)
+		anyvec.Rand(vec, anyvec.Normal, nil)
+		data := vec.Data().([]float32)
+		if len(data) != n {
+			t.Fatalf("expected length %d but got %d", n, len(data))
+		}
+		for i, x := range data {
+			if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+				t.Fatalf("entry %d is not finite: %f", i, x)
+			}
+		}
+	})
+}
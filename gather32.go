@@ -0,0 +1,57 @@
+package cudavec
+
+import "github.com/unixpickle/anyvec"
+
+// Gather sets dst[i] to src[indices[i]] for every index.
+//
+// Out-of-range indices produce zeros.
+func Gather(dst, src anyvec.Vector, indices *IntVector) {
+	if dst.Len() != indices.Len() {
+		panic("length mismatch")
+	}
+	indexOp("gather", dst.(*vector32), src.(*vector32), indices, src.Len())
+}
+
+// ScatterAdd adds src[i] to dst[indices[i]] for every
+// index.
+//
+// Out-of-range indices are ignored.
+func ScatterAdd(dst anyvec.Vector, indices *IntVector, src anyvec.Vector) {
+	if src.Len() != indices.Len() {
+		panic("length mismatch")
+	}
+	indexOp("scatterAdd", dst.(*vector32), src.(*vector32), indices, dst.Len())
+}
+
+// ScatterMax sets dst[indices[i]] to the maximum of its
+// current value and src[i] for every index.
+//
+// Out-of-range indices are ignored.
+func ScatterMax(dst anyvec.Vector, indices *IntVector, src anyvec.Vector) {
+	if src.Len() != indices.Len() {
+		panic("length mismatch")
+	}
+	indexOp("scatterMax", dst.(*vector32), src.(*vector32), indices, dst.Len())
+}
+
+func indexOp(kernel string, dst, src *vector32, indices *IntVector, limit int) {
+	dst.creator.Handle.sanitize([]operand{dst}, []operand{src, indices})
+	if dst.Overlaps(src) {
+		panic("invalid overlap")
+	} else if int(int32(limit)) != limit || int(int32(indices.Len())) != indices.Len() {
+		panic("vector is too big to index")
+	}
+	if indices.Len() == 0 {
+		return
+	}
+	dst.run([]*vector32{dst}, func() error {
+		if err := lazyInitAll(true, dst, src); err != nil {
+			return err
+		}
+		if err := indices.lazyInit(true); err != nil {
+			return err
+		}
+		return dst.creator.Handle.launchFlat(kernel, indices.Len(),
+			dst.buffer, src.buffer, indices.buffer, indices.Len(), limit)
+	})
+}
@@ -0,0 +1,53 @@
+package cudavec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGatherScatter(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	src := c.MakeVectorData([]float32{1, -2, 3, 5})
+	indices := c.MakeIntVectorData([]int32{3, 0, 0, 7, 2})
+
+	dst := c.MakeVector(5)
+	Gather(dst, src, indices)
+	assertClose(t, "gather", dst.Data().([]float32), []float32{5, 1, 1, 0, 3})
+
+	values := c.MakeVectorData([]float32{1, 2, 3, 4, 5})
+	sums := c.MakeVectorData([]float32{1, 1, 1, 1})
+	ScatterAdd(sums, indices, values)
+	assertClose(t, "scatter add", sums.Data().([]float32), []float32{6, 1, 6, 2})
+
+	maxes := c.MakeVectorData([]float32{2.5, 1, 10, -1})
+	ScatterMax(maxes, indices, values)
+	assertClose(t, "scatter max", maxes.Data().([]float32), []float32{3, 1, 10, 1})
+}
+
+func TestMakeMapperIndices(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	vec := c.MakeVectorData([]float32{1, 3, 2, 7, 4, 5})
+	indices := MapperIndices(vec.(*vector32).MapMax(3))
+	mapper := c.MakeMapperIndices(vec.Len(), indices)
+
+	out := c.MakeVector(2)
+	mapper.Map(vec, out)
+	assertClose(t, "map", out.Data().([]float32), []float32{3, 7})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for out-of-range index")
+			}
+		}()
+		c.MakeMapperIndices(3, indices)
+	}()
+
+	if table := indices.Data(); !reflect.DeepEqual(table, []int32{1, 3}) {
+		t.Errorf("unexpected indices: %v", table)
+	}
+}
@@ -1,5 +1,11 @@
 package cudavec
 
+import (
+	"testing"
+
+	"github.com/unixpickle/cuda"
+)
+
 var testingHandle *Handle
 
 type Fataler interface {
@@ -17,3 +23,19 @@ func setupTest(f Fataler) *Handle {
 	}
 	return testingHandle
 }
+
+// setupDeviceTest is like setupTest, but it skips the test
+// when there is no CUDA device.
+//
+// There is no host emulator for the kernels, so tests that
+// compare against anyvec32 on CI machines without a GPU
+// are skipped rather than failed.
+func setupDeviceTest(t testing.TB) *Handle {
+	if testingHandle == nil {
+		devs, err := cuda.AllDevices()
+		if err != nil || len(devs) == 0 {
+			t.Skip("no CUDA device available")
+		}
+	}
+	return setupTest(t)
+}
@@ -0,0 +1,111 @@
+// Package kernelsig parses the signatures of the CUDA
+// kernels in a source file and stores them in a manifest.
+package kernelsig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ManifestName is the name of the manifest file in the
+// generated kernel directory.
+const ManifestName = "manifest.json"
+
+// A Manifest describes the generated kernels.
+type Manifest struct {
+	// Source is the CUDA file the kernels came from.
+	Source string `json:"source"`
+
+	// Archs lists the virtual architectures that PTX was
+	// generated for, such as "compute_50".
+	Archs []string `json:"archs"`
+
+	// Kernels lists every kernel in the source file.
+	Kernels []*Kernel `json:"kernels"`
+}
+
+// ReadManifest decodes a manifest.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	var res Manifest
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Write encodes the manifest as indented JSON.
+func (m *Manifest) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Kernel finds a kernel by name.
+// It returns nil if there is no such kernel.
+func (m *Manifest) Kernel(name string) *Kernel {
+	for _, k := range m.Kernels {
+		if k.Name == name {
+			return k
+		}
+	}
+	return nil
+}
+
+// A Kernel is the signature of a __global__ function.
+type Kernel struct {
+	Name   string   `json:"name"`
+	Params []*Param `json:"params"`
+}
+
+// A Param is a single kernel parameter.
+type Param struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+var (
+	commentExpr = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	kernelExpr  = regexp.MustCompile(`extern\s+"C"\s+__global__\s+void\s+(\w+)\s*\(([^)]*)\)`)
+	paramExpr   = regexp.MustCompile(`^(.*?)\s*(\w+)$`)
+	spaceExpr   = regexp.MustCompile(`\s+`)
+)
+
+// Parse finds every kernel declared with
+// extern "C" __global__ in CUDA source code.
+func Parse(source string) ([]*Kernel, error) {
+	source = commentExpr.ReplaceAllString(source, "")
+	var res []*Kernel
+	names := map[string]bool{}
+	for _, match := range kernelExpr.FindAllStringSubmatch(source, -1) {
+		kernel := &Kernel{Name: match[1]}
+		if names[kernel.Name] {
+			return nil, fmt.Errorf("duplicate kernel: %s", kernel.Name)
+		}
+		names[kernel.Name] = true
+		params := strings.TrimSpace(match[2])
+		if params == "" || params == "void" {
+			res = append(res, kernel)
+			continue
+		}
+		for _, param := range strings.Split(params, ",") {
+			param = spaceExpr.ReplaceAllString(strings.TrimSpace(param), " ")
+			parts := paramExpr.FindStringSubmatch(param)
+			if parts == nil || parts[1] == "" {
+				return nil, fmt.Errorf("kernel %s: bad parameter %q", kernel.Name, param)
+			}
+			kernel.Params = append(kernel.Params, &Param{Type: parts[1], Name: parts[2]})
+		}
+		res = append(res, kernel)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no kernels found")
+	}
+	return res, nil
+}
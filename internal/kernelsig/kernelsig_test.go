@@ -0,0 +1,126 @@
+package kernelsig
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	source := `
+// helper is not a kernel.
+extern "C" __device__
+float helper(float x) {
+	return x;
+}
+
+/* extern "C" __global__ void commented(int x) {} */
+
+extern "C" __global__
+void scale(float s, float * dest,
+	long long   destLen) {
+}
+
+extern "C" __global__ void empty() {}
+`
+	kernels, err := Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Kernel{
+		{
+			Name: "scale",
+			Params: []*Param{
+				{Type: "float", Name: "s"},
+				{Type: "float *", Name: "dest"},
+				{Type: "long long", Name: "destLen"},
+			},
+		},
+		{Name: "empty"},
+	}
+	if !reflect.DeepEqual(kernels, expected) {
+		t.Errorf("unexpected kernels: %v", kernels)
+	}
+
+	var buf bytes.Buffer
+	manifest := &Manifest{Source: "k.cu", Archs: []string{"compute_50"}, Kernels: kernels}
+	if err := manifest.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, manifest) {
+		t.Error("manifest did not round trip")
+	}
+	if decoded.Kernel("scale") == nil || decoded.Kernel("helper") != nil {
+		t.Error("bad kernel lookup")
+	}
+
+	if _, err := Parse(source + source); err == nil {
+		t.Error("expected error for duplicate kernels")
+	}
+}
+
+func TestCheckPTX(t *testing.T) {
+	kernels := []*Kernel{
+		{
+			Name: "scale",
+			Params: []*Param{
+				{Type: "float", Name: "s"},
+				{Type: "float *", Name: "dest"},
+				{Type: "long long", Name: "destLen"},
+			},
+		},
+		{Name: "empty"},
+	}
+	ptx := `
+.version 7.0
+.target sm_80
+.address_size 64
+
+	// .globl	scale
+.visible .entry scale(
+	.param .f32 scale_param_0,
+	.param .u64 .ptr .global .align 4 scale_param_1,
+	.param .u64 scale_param_2
+)
+{
+	ret;
+}
+
+	// .globl	empty
+.visible .entry empty()
+{
+	ret;
+}
+`
+	entries, err := ParsePTX(ptx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*PTXEntry{
+		{Name: "scale", ParamBits: []int{32, 64, 64}},
+		{Name: "empty"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+	if err := CheckPTX(kernels, ptx); err != nil {
+		t.Error(err)
+	}
+
+	narrow := strings.Replace(ptx, ".u64 scale_param_2", ".u32 scale_param_2", 1)
+	if err := CheckPTX(kernels, narrow); err == nil {
+		t.Error("expected error for narrow parameter")
+	}
+	if err := CheckPTX(kernels[:1], ptx); err == nil {
+		t.Error("expected error for extra entry")
+	}
+	extra := append([]*Kernel{{Name: "missing"}}, kernels...)
+	if err := CheckPTX(extra, ptx); err == nil {
+		t.Error("expected error for missing entry")
+	}
+}
@@ -0,0 +1,133 @@
+package kernelsig
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A PTXEntry is the signature of a kernel entry point in
+// generated PTX.
+type PTXEntry struct {
+	Name string
+
+	// ParamBits stores the width of each parameter.
+	ParamBits []int
+}
+
+var (
+	ptxCommentExpr = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	entryExpr      = regexp.MustCompile(`\.entry\s+(\w+)\s*\(([^)]*)\)`)
+	ptxTypeExpr    = regexp.MustCompile(`^\.[bsuf](8|16|32|64)$`)
+	arraySizeExpr  = regexp.MustCompile(`\[(\d+)\]$`)
+)
+
+// ParsePTX finds every .entry in PTX code.
+func ParsePTX(ptx string) ([]*PTXEntry, error) {
+	ptx = ptxCommentExpr.ReplaceAllString(ptx, "")
+	var res []*PTXEntry
+	for _, match := range entryExpr.FindAllStringSubmatch(ptx, -1) {
+		entry := &PTXEntry{Name: match[1]}
+		params := strings.TrimSpace(match[2])
+		if params == "" {
+			res = append(res, entry)
+			continue
+		}
+		for _, param := range strings.Split(params, ",") {
+			bits, err := ptxParamBits(strings.Fields(param))
+			if err != nil {
+				return nil, fmt.Errorf("entry %s: %s", entry.Name, err)
+			}
+			entry.ParamBits = append(entry.ParamBits, bits)
+		}
+		res = append(res, entry)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no entries found")
+	}
+	return res, nil
+}
+
+// ptxParamBits finds the width of a parameter such as
+// ".param .u64 .ptr .global .align 4 name".
+func ptxParamBits(fields []string) (int, error) {
+	if len(fields) < 3 || fields[0] != ".param" {
+		return 0, fmt.Errorf("bad parameter %q", strings.Join(fields, " "))
+	}
+	for _, field := range fields[1 : len(fields)-1] {
+		match := ptxTypeExpr.FindStringSubmatch(field)
+		if match == nil {
+			continue
+		}
+		bits, _ := strconv.Atoi(match[1])
+		if size := arraySizeExpr.FindStringSubmatch(fields[len(fields)-1]); size != nil {
+			count, _ := strconv.Atoi(size[1])
+			bits *= count
+		}
+		return bits, nil
+	}
+	return 0, fmt.Errorf("parameter %q has no type", strings.Join(fields, " "))
+}
+
+// ParamBits finds the width of a kernel parameter type
+// when it is passed to a 64-bit device.
+func ParamBits(typ string) (int, error) {
+	if strings.HasSuffix(typ, "*") {
+		return 64, nil
+	}
+	switch typ {
+	case "char", "unsigned char", "bool":
+		return 8, nil
+	case "short", "unsigned short":
+		return 16, nil
+	case "int", "unsigned int", "unsigned", "float":
+		return 32, nil
+	case "long long", "unsigned long long", "double":
+		return 64, nil
+	}
+	return 0, fmt.Errorf("unknown parameter type: %s", typ)
+}
+
+// CheckPTX checks that PTX code has exactly one entry for
+// each kernel, and that the width of every parameter
+// matches the kernel's signature.
+func CheckPTX(kernels []*Kernel, ptx string) error {
+	entries, err := ParsePTX(ptx)
+	if err != nil {
+		return err
+	}
+	byName := map[string]*PTXEntry{}
+	for _, entry := range entries {
+		if byName[entry.Name] != nil {
+			return fmt.Errorf("duplicate entry: %s", entry.Name)
+		}
+		byName[entry.Name] = entry
+	}
+	for _, kernel := range kernels {
+		entry := byName[kernel.Name]
+		if entry == nil {
+			return fmt.Errorf("missing entry: %s", kernel.Name)
+		}
+		delete(byName, kernel.Name)
+		if len(entry.ParamBits) != len(kernel.Params) {
+			return fmt.Errorf("entry %s: expected %d parameters but got %d", kernel.Name,
+				len(kernel.Params), len(entry.ParamBits))
+		}
+		for i, param := range kernel.Params {
+			bits, err := ParamBits(param.Type)
+			if err != nil {
+				return fmt.Errorf("kernel %s: %s", kernel.Name, err)
+			}
+			if bits != entry.ParamBits[i] {
+				return fmt.Errorf("entry %s: parameter %s (%s) is %d bits but got %d",
+					kernel.Name, param.Name, param.Type, bits, entry.ParamBits[i])
+			}
+		}
+	}
+	for name := range byName {
+		return fmt.Errorf("unexpected entry: %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package cudavec
+
+import (
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/cuda"
+)
+
+// An IntVector is a vector of int32 values stored on the
+// device.
+//
+// IntVectors can be used as indices for Gather and the
+// scatter operations, or as mapper tables, without a
+// round-trip to the host.
+type IntVector struct {
+	creator *Creator32
+	size    int
+
+	// Used to detect overlap.
+	bufferID *int
+	start    int
+
+	// May be nil for lazy evaluations.
+	buffer cuda.Buffer
+}
+
+// MakeIntVector creates a zero'd out IntVector.
+func (c *Creator32) MakeIntVector(size int) *IntVector {
+	if size < 0 {
+		panic("size cannot be negative")
+	}
+	return &IntVector{creator: c, size: size, bufferID: new(int)}
+}
+
+// MakeIntVectorData creates an IntVector with the
+// specified contents.
+func (c *Creator32) MakeIntVectorData(data []int32) *IntVector {
+	res := c.MakeIntVector(len(data))
+	res.SetData(data)
+	return res
+}
+
+// ToIntVector converts a vector from a Creator32 to an
+// IntVector, rounding each value to the nearest integer.
+func ToIntVector(v anyvec.Vector) *IntVector {
+	v32 := v.(*vector32)
+	res := v32.creator.MakeIntVector(v.Len())
+	if v.Len() == 0 {
+		return res
+	}
+	v32.run(nil, func() error {
+		if err := v32.lazyInit(true); err != nil {
+			return err
+		}
+		if err := res.lazyInit(false); err != nil {
+			return err
+		}
+		return v32.creator.Handle.launchFlat("floatToInt", res.Len(),
+			res.buffer, v32.buffer, res.Len())
+	})
+	return res
+}
+
+// ArgMax computes the column index of the maximum value in
+// each row of a row-major matrix.
+func ArgMax(v anyvec.Vector, cols int) *IntVector {
+	if cols <= 0 {
+		panic("column count must be positive")
+	} else if v.Len()%cols != 0 {
+		panic("column count must divide vector size")
+	}
+	v32 := v.(*vector32)
+	res := v32.creator.MakeIntVector(v.Len() / cols)
+	if res.Len() == 0 {
+		return res
+	}
+	v32.run(nil, func() error {
+		if err := v32.lazyInit(true); err != nil {
+			return err
+		}
+		if err := res.lazyInit(false); err != nil {
+			return err
+		}
+		return v32.creator.Handle.launchRows("argMax", res.Len(), cols,
+			res.buffer, v32.buffer, res.Len(), cols)
+	})
+	return res
+}
+
+// MapperIndices returns a copy of the table of a Mapper
+// created by a Creator32.
+//
+// Entry i of the result is the input index that a Map
+// will store at output index i.
+// For example, the indices of a mapper from MapMax are
+// the flat indices of each row's maximum.
+func MapperIndices(m anyvec.Mapper) *IntVector {
+	m32 := m.(*mapper32)
+	res := m32.creator.MakeIntVector(m32.outSize)
+	m32.creator.run(func() error {
+		if err := res.lazyInit(false); err != nil {
+			return err
+		}
+		return cuda.CopyBuffer(res.buffer, m32.table)
+	})
+	return res
+}
+
+// MakeMapperIndices creates a mapper whose table is a copy
+// of an IntVector.
+//
+// The indices are validated on the device, so only the
+// validation result is copied to the host.
+func (c *Creator32) MakeMapperIndices(inSize int, indices *IntVector) anyvec.Mapper {
+	if inSize < 0 {
+		panic("input size out of range")
+	} else if int(int32(inSize)) != inSize || int(int32(indices.Len())) != indices.Len() {
+		panic("mapper size is too big")
+	}
+	res := &mapper32{creator: c, inSize: inSize, outSize: indices.Len()}
+	var badCount int32
+	c.runSync(func() error {
+		if err := indices.lazyInit(true); err != nil {
+			return err
+		}
+		buf, err := c.Handle.allocBuffer(SiteMapper, uintptr(indices.Len())*4)
+		if err != nil {
+			return err
+		}
+		if err := cuda.CopyBuffer(buf, indices.buffer); err != nil {
+			return err
+		}
+		res.table = buf
+		if indices.Len() == 0 {
+			return nil
+		}
+
+		count, err := c.Handle.scratchBuffer(SiteTemp, 4)
+		if err != nil {
+			return err
+		}
+		if err := cuda.ClearBuffer(count); err != nil {
+			return err
+		}
+		err = c.Handle.launchFlat("countOutOfRange", indices.Len(),
+			count, indices.buffer, indices.Len(), inSize)
+		if err != nil {
+			return err
+		}
+		counts := make([]int32, 1)
+		if err := cuda.ReadBuffer(counts, count); err != nil {
+			return err
+		}
+		badCount = counts[0]
+		return nil
+	})
+	if badCount != 0 {
+		panic("index out of range")
+	}
+	return res
+}
+
+// Creator returns the Creator32 that made the vector.
+func (v *IntVector) Creator() *Creator32 {
+	return v.creator
+}
+
+// Len returns the number of elements in the vector.
+func (v *IntVector) Len() int {
+	return v.size
+}
+
+// Overlaps checks if two IntVectors share any memory.
+func (v *IntVector) Overlaps(v1 *IntVector) bool {
+	return v1.bufferID == v.bufferID &&
+		v.start < v1.start+v1.Len() &&
+		v1.start < v.start+v.Len()
+}
+
+// Data copies the vector's contents to the host.
+func (v *IntVector) Data() []int32 {
+	res := make([]int32, v.Len())
+	v.creator.runSync(func() error {
+		if v.buffer != nil {
+			return cuda.ReadBuffer(res, v.buffer)
+		}
+		return nil
+	})
+	return res
+}
+
+// SetData copies data from the host into the beginning of
+// the vector.
+func (v *IntVector) SetData(data []int32) {
+	if len(data) > v.Len() {
+		panic("index out of range")
+	}
+	v.creator.runSync(func() error {
+		if err := v.lazyInit(len(data) < v.Len()); err != nil {
+			return err
+		}
+		return cuda.WriteBuffer(v.buffer, data)
+	})
+}
+
+// Set copies the contents of v1 into v.
+func (v *IntVector) Set(v1 *IntVector) {
+	v.assertCompat(v1)
+	v.creator.run(func() error {
+		if v1.buffer == nil {
+			if v.buffer != nil {
+				return cuda.ClearBuffer(v.buffer)
+			}
+			return nil
+		}
+		if err := v.lazyInit(false); err != nil {
+			return err
+		}
+		return cuda.CopyBuffer(v.buffer, v1.buffer)
+	})
+}
+
+// Copy creates a copy of the vector.
+func (v *IntVector) Copy() *IntVector {
+	res := v.creator.MakeIntVector(v.Len())
+	res.Set(v)
+	return res
+}
+
+// Slice creates a vector which shares memory with a range
+// of this vector.
+func (v *IntVector) Slice(start, end int) *IntVector {
+	if start < 0 || start > end || end > v.Len() {
+		panic("index out of range")
+	}
+	res := &IntVector{
+		creator:  v.creator,
+		size:     end - start,
+		bufferID: v.bufferID,
+		start:    v.start + start,
+	}
+	v.creator.run(func() error {
+		if err := v.lazyInit(true); err != nil {
+			return err
+		}
+		res.buffer = cuda.Slice(v.buffer, uintptr(start)*4, uintptr(end)*4)
+		return nil
+	})
+	return res
+}
+
+// ToVector converts the vector to a float32 vector.
+func (v *IntVector) ToVector() anyvec.Vector {
+	res := v.creator.MakeVector(v.Len()).(*vector32)
+	if v.Len() == 0 {
+		return res
+	}
+	v.creator.run(func() error {
+		if v.buffer == nil {
+			return nil
+		}
+		if err := res.lazyInit(false); err != nil {
+			return err
+		}
+		return v.creator.Handle.launchFlat("intToFloat", v.Len(),
+			res.buffer, v.buffer, v.Len())
+	})
+	return res
+}
+
+// Add adds v1 to v.
+func (v *IntVector) Add(v1 *IntVector) {
+	v.binaryOp("addInts", v1)
+}
+
+// Sub subtracts v1 from v.
+func (v *IntVector) Sub(v1 *IntVector) {
+	v.binaryOp("subInts", v1)
+}
+
+// Mul multiplies v by v1 element-wise.
+func (v *IntVector) Mul(v1 *IntVector) {
+	v.binaryOp("mulInts", v1)
+}
+
+// Div divides v by v1 element-wise, truncating towards
+// zero.
+// Division by zero yields zero.
+func (v *IntVector) Div(v1 *IntVector) {
+	v.binaryOp("divInts", v1)
+}
+
+// AddScalar adds s to every element.
+func (v *IntVector) AddScalar(s int32) {
+	v.scalarOp("addScalerInt", s)
+}
+
+// Scale multiplies every element by s.
+func (v *IntVector) Scale(s int32) {
+	v.scalarOp("scaleInt", s)
+}
+
+// LessThan sets every element to 1 if it is less than s,
+// or to 0 otherwise.
+func (v *IntVector) LessThan(s int32) {
+	v.scalarOp("lessThanInt", s)
+}
+
+// GreaterThan sets every element to 1 if it is greater
+// than s, or to 0 otherwise.
+func (v *IntVector) GreaterThan(s int32) {
+	v.scalarOp("greaterThanInt", s)
+}
+
+// EqualTo sets every element to 1 if it is equal to s,
+// or to 0 otherwise.
+func (v *IntVector) EqualTo(s int32) {
+	v.scalarOp("equalToInt", s)
+}
+
+func (v *IntVector) binaryOp(kernel string, v1 *IntVector) {
+	v.assertCompat(v1)
+	if v.Len() == 0 {
+		return
+	}
+	v.creator.run(func() error {
+		if err := v.lazyInit(true); err != nil {
+			return err
+		}
+		if err := v1.lazyInit(true); err != nil {
+			return err
+		}
+		return v.creator.Handle.launchFlat(kernel, v.Len(),
+			v.buffer, v1.buffer, v.Len())
+	})
+}
+
+func (v *IntVector) scalarOp(kernel string, s int32) {
+	if v.Len() == 0 {
+		return
+	}
+	v.creator.run(func() error {
+		if err := v.lazyInit(true); err != nil {
+			return err
+		}
+		return v.creator.Handle.launchFlat(kernel, v.Len(),
+			int(s), v.buffer, v.Len())
+	})
+}
+
+func (v *IntVector) assertCompat(v1 *IntVector) {
+	v.creator.Handle.sanitize([]operand{v}, []operand{v1})
+	if v.Overlaps(v1) {
+		panic("invalid overlap")
+	} else if v.Len() != v1.Len() {
+		panic("length mismatch")
+	}
+}
+
+func (v *IntVector) lazyInit(clear bool) error {
+	if v.buffer != nil {
+		return nil
+	}
+	var err error
+	v.buffer, err = v.creator.Handle.allocBuffer(SiteLazyInit, uintptr(v.Len())*4)
+	if err != nil {
+		return err
+	}
+	if clear {
+		return cuda.ClearBuffer(v.buffer)
+	}
+	return nil
+}
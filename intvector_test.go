@@ -0,0 +1,76 @@
+package cudavec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntVector(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	v := c.MakeIntVectorData([]int32{3, -2, 7, 0, 5})
+	v1 := c.MakeIntVectorData([]int32{1, 2, 3, 4, 0})
+
+	sum := v.Copy()
+	sum.Add(v1)
+	assertInts(t, "add", sum.Data(), []int32{4, 0, 10, 4, 5})
+
+	diff := v.Copy()
+	diff.Sub(v1)
+	assertInts(t, "sub", diff.Data(), []int32{2, -4, 4, -4, 5})
+
+	prod := v.Copy()
+	prod.Mul(v1)
+	assertInts(t, "mul", prod.Data(), []int32{3, -4, 21, 0, 0})
+
+	quo := v.Copy()
+	quo.Div(v1)
+	assertInts(t, "div", quo.Data(), []int32{3, -1, 2, 0, 0})
+
+	shifted := v.Copy()
+	shifted.AddScalar(2)
+	shifted.Scale(-3)
+	assertInts(t, "scalars", shifted.Data(), []int32{-15, 0, -27, -6, -21})
+
+	less := v.Copy()
+	less.LessThan(3)
+	assertInts(t, "less than", less.Data(), []int32{0, 1, 0, 1, 0})
+
+	greater := v.Copy()
+	greater.GreaterThan(3)
+	assertInts(t, "greater than", greater.Data(), []int32{0, 0, 1, 0, 1})
+
+	equal := v.Copy()
+	equal.EqualTo(0)
+	assertInts(t, "equal to", equal.Data(), []int32{0, 0, 0, 1, 0})
+
+	slice := v.Slice(1, 4)
+	assertInts(t, "slice", slice.Data(), []int32{-2, 7, 0})
+	if !slice.Overlaps(v) || slice.Overlaps(v1) {
+		t.Error("bad overlap detection")
+	}
+	slice.AddScalar(1)
+	assertInts(t, "sliced add", v.Data(), []int32{3, -1, 8, 1, 5})
+}
+
+func TestIntVectorConversion(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	floats := c.MakeVectorData([]float32{1.2, -3.7, 2, 0.4})
+	ints := ToIntVector(floats)
+	assertInts(t, "to ints", ints.Data(), []int32{1, -4, 2, 0})
+	assertClose(t, "to floats", ints.ToVector().Data().([]float32),
+		[]float32{1, -4, 2, 0})
+
+	matrix := c.MakeVectorData([]float32{1, 3, 2, 7, 4, 5})
+	assertInts(t, "argmax", ArgMax(matrix, 3).Data(), []int32{1, 0})
+	assertInts(t, "argmax", ArgMax(matrix, 2).Data(), []int32{1, 1, 1})
+}
+
+func assertInts(t *testing.T, name string, actual, expected []int32) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%s: expected %v but got %v", name, expected, actual)
+	}
+}
@@ -0,0 +1,130 @@
+package cudavec
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/cuda"
+	"github.com/unixpickle/cudavec/internal/kernelsig"
+)
+
+//go:generate go run ./cmd/cudavec-genkernels -src kernels32.cu -out kernels
+
+// kernelFiles stores the generated PTX for every
+// architecture, along with the manifest.
+//
+//go:embed kernels/*.ptx kernels/manifest.json
+var kernelFiles embed.FS
+
+// readKernelManifest reads the manifest of the generated
+// kernels.
+func readKernelManifest() (*kernelsig.Manifest, error) {
+	f, err := kernelFiles.Open(path.Join("kernels", kernelsig.ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return kernelsig.ReadManifest(f)
+}
+
+// kernelPTX reads the PTX for an architecture.
+func kernelPTX(arch string) (string, error) {
+	data, err := kernelFiles.ReadFile(path.Join("kernels", "kernels32_"+arch+".ptx"))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// A computeCapability is the version of a device's
+// architecture, such as 5.2.
+type computeCapability struct {
+	Major int
+	Minor int
+}
+
+// parseArch parses a virtual architecture like
+// "compute_52".
+func parseArch(arch string) (computeCapability, bool) {
+	if !strings.HasPrefix(arch, "compute_") {
+		return computeCapability{}, false
+	}
+	num, err := strconv.Atoi(strings.TrimPrefix(arch, "compute_"))
+	if err != nil || num < 10 {
+		return computeCapability{}, false
+	}
+	return computeCapability{Major: num / 10, Minor: num % 10}, true
+}
+
+func (c computeCapability) less(c1 computeCapability) bool {
+	return c.Major < c1.Major || (c.Major == c1.Major && c.Minor < c1.Minor)
+}
+
+// selectArchs orders the embedded architectures by how
+// well they suit a device.
+//
+// PTX for an architecture newer than the device cannot run
+// on it, so those architectures are dropped.
+// The rest are ordered from newest to oldest, since newer
+// PTX can use more features (such as faster atomics) and
+// the driver compiles it for the exact device.
+// Later entries are fallbacks in case the driver cannot
+// load the newer PTX.
+func selectArchs(archs []string, cc computeCapability) []string {
+	type parsedArch struct {
+		name string
+		cc   computeCapability
+	}
+	var parsed []parsedArch
+	for _, arch := range archs {
+		archCC, ok := parseArch(arch)
+		if !ok || cc.less(archCC) {
+			continue
+		}
+		parsed = append(parsed, parsedArch{name: arch, cc: archCC})
+	}
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return parsed[j].cc.less(parsed[i].cc)
+	})
+	res := make([]string, len(parsed))
+	for i, arch := range parsed {
+		res[i] = arch.name
+	}
+	return res
+}
+
+// loadKernels loads the most suitable embedded PTX for a
+// device, following the order from selectArchs.
+func loadKernels(ctx *cuda.Context, cc computeCapability) (*cuda.Module, error) {
+	manifest, err := readKernelManifest()
+	if err != nil {
+		return nil, err
+	}
+	archs := selectArchs(manifest.Archs, cc)
+	if len(archs) == 0 {
+		return nil, fmt.Errorf("no embedded kernels for compute capability %d.%d "+
+			"(embedded: %s)", cc.Major, cc.Minor, strings.Join(manifest.Archs, ", "))
+	}
+	var errs []string
+	for _, arch := range archs {
+		module, err := loadKernelArch(ctx, arch)
+		if err == nil {
+			return module, nil
+		}
+		errs = append(errs, arch+": "+err.Error())
+	}
+	return nil, errors.New("load kernels: " + strings.Join(errs, "; "))
+}
+
+func loadKernelArch(ctx *cuda.Context, arch string) (*cuda.Module, error) {
+	ptx, err := kernelPTX(arch)
+	if err != nil {
+		return nil, err
+	}
+	return cuda.NewModule(ctx, ptx)
+}
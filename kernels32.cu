@@ -1,71 +1,69 @@
+// GRID_LOOP visits every index below n with a grid-stride
+// loop, so that a grid of any size covers the whole range.
+// The index is 64 bits so that it cannot overflow.
+#define GRID_LOOP(i, n) \
+	for (long long i = (long long)blockIdx.x*blockDim.x + threadIdx.x; \
+		i < (n); i += (long long)blockDim.x*gridDim.x)
+
 extern "C" __global__
-void divElements(float * x, float * y, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void divElements(float * x, float * y, long long n) {
+	GRID_LOOP(tid, n) {
 		x[tid] /= y[tid];
 	}
 }
 
 extern "C" __global__
-void elemMax(float * dst, float * src, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void elemMax(float * dst, float * src, long long n) {
+	GRID_LOOP(tid, n) {
 		dst[tid] = max(dst[tid], src[tid]);
 	}
 }
 
 extern "C" __global__
-void expElements(float * x, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void expElements(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		x[tid] = expf(x[tid]);
 	}
 }
 
 extern "C" __global__
-void logElements(float * x, int n) {
-  int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void logElements(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		x[tid] = logf(x[tid]);
 	}
 }
 
 extern "C" __global__
-void tanhElements(float * x, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void tanhElements(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		x[tid] = tanhf(x[tid]);
 	}
 }
 
 extern "C" __global__
-void sinElements(float * x, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void sinElements(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		x[tid] = sinf(x[tid]);
 	}
 }
 
 extern "C" __global__
-void sigmoidElements(float * x, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void sigmoidElements(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		x[tid] = (1 + tanhf(x[tid] / 2)) / 2;
 	}
 }
 
 extern "C" __global__
-void clipPositive(float * x, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void clipPositive(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		x[tid] = fmaxf(0, x[tid]);
 	}
 }
 
 extern "C" __global__
-void shiftRandUniform(float * x, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void shiftRandUniform(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		if (x[tid] == 1.0f) {
 			x[tid] = 0;
 		}
@@ -73,9 +71,8 @@ void shiftRandUniform(float * x, int n) {
 }
 
 extern "C" __global__
-void uniformToBernoulli(float * x, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void uniformToBernoulli(float * x, long long n) {
+	GRID_LOOP(tid, n) {
 		if (x[tid] > 0.5) {
 			x[tid] = 1;
 		} else {
@@ -85,73 +82,64 @@ void uniformToBernoulli(float * x, int n) {
 }
 
 extern "C" __global__
-void addRepeated(float * dest, float * source, int destLen, int sourceLen) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void addRepeated(float * dest, float * source, long long destLen, int sourceLen) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] += source[tid % sourceLen];
 	}
 }
 
 extern "C" __global__
-void addRepeatedPow2(float * dest, float * source, int destLen, int srcMask) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void addRepeatedPow2(float * dest, float * source, long long destLen, int srcMask) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] += source[tid & srcMask];
 	}
 }
 
 extern "C" __global__
-void scaleRepeated(float * dest, float * source, int destLen, int sourceLen) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void scaleRepeated(float * dest, float * source, long long destLen, int sourceLen) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] *= source[tid % sourceLen];
 	}
 }
 
 extern "C" __global__
-void scaleRepeatedPow2(float * dest, float * source, int destLen, int srcMask) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void scaleRepeatedPow2(float * dest, float * source, long long destLen, int srcMask) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] *= source[tid & srcMask];
 	}
 }
 
 extern "C" __global__
-void addScaler(float s, float * dest, int destLen) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void addScaler(float s, float * dest, long long destLen) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] += s;
 	}
 }
 
 extern "C" __global__
-void setScaler(float s, float * dest, int destLen) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void setScaler(float s, float * dest, long long destLen) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] = s;
 	}
 }
 
 extern "C" __global__
-void addChunks(float * dest, float * source, int destLen, int chunkSize) {
-  int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void addChunks(float * dest, float * source, long long destLen, int chunkSize) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] += source[tid / chunkSize];
 	}
 }
 
 extern "C" __global__
-void subChunks(float * dest, float * source, int destLen, int chunkSize) {
-  int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void subChunks(float * dest, float * source, long long destLen, int chunkSize) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] -= source[tid / chunkSize];
 	}
 }
 
 extern "C" __global__
-void lessThan(float s, float * v, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void lessThan(float s, float * v, long long n) {
+	GRID_LOOP(tid, n) {
     if (v[tid] < s) {
       v[tid] = 1;
     } else {
@@ -161,9 +149,8 @@ void lessThan(float s, float * v, int n) {
 }
 
 extern "C" __global__
-void greaterThan(float s, float * v, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void greaterThan(float s, float * v, long long n) {
+	GRID_LOOP(tid, n) {
     if (v[tid] > s) {
       v[tid] = 1;
     } else {
@@ -173,9 +160,8 @@ void greaterThan(float s, float * v, int n) {
 }
 
 extern "C" __global__
-void equalTo(float s, float * v, int n) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < n) {
+void equalTo(float s, float * v, long long n) {
+	GRID_LOOP(tid, n) {
     if (v[tid] == s) {
       v[tid] = 1;
     } else {
@@ -191,57 +177,59 @@ float addLogPair(float x, float y) {
 }
 
 extern "C" __global__
-void addLogs(float * dst, float * src, int rowSize) {
+void addLogs(float * dst, float * src, int rows, int rowSize) {
   extern __shared__ float chunk[];
 
-  int rowIdx = blockIdx.y * blockDim.x + threadIdx.x;
-  if (rowIdx < rowSize) {
-    chunk[threadIdx.x] = src[rowIdx+rowSize*blockIdx.x];
-  }
-  __syncthreads();
+  int dstCols = (rowSize + blockDim.x - 1) / blockDim.x;
+  for (int row = blockIdx.x; row < rows; row += gridDim.x) {
+    for (int col = blockIdx.y; col < dstCols; col += gridDim.y) {
+      int rowIdx = col * blockDim.x + threadIdx.x;
+      if (rowIdx < rowSize) {
+        chunk[threadIdx.x] = src[rowIdx+(long long)rowSize*row];
+      }
+      __syncthreads();
 
-  for (int stride = (blockDim.x>>1); stride >= 1; stride >>= 1) {
-    if (threadIdx.x < stride && rowIdx+stride < rowSize) {
-      chunk[threadIdx.x] = addLogPair(chunk[threadIdx.x],
-        chunk[threadIdx.x+stride]);
-    }
-    __syncthreads();
-  }
+      for (int stride = (blockDim.x>>1); stride >= 1; stride >>= 1) {
+        if (threadIdx.x < stride && rowIdx+stride < rowSize) {
+          chunk[threadIdx.x] = addLogPair(chunk[threadIdx.x],
+            chunk[threadIdx.x+stride]);
+        }
+        __syncthreads();
+      }
 
-  if (threadIdx.x == 0) {
-    dst[blockIdx.y + blockIdx.x*gridDim.y] = chunk[0];
+      if (threadIdx.x == 0) {
+        dst[col + (long long)row*dstCols] = chunk[0];
+      }
+      __syncthreads();
+    }
   }
 }
 
 extern "C" __global__
-void powScaler(float s, float * dest, int destLen) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < destLen) {
+void powScaler(float s, float * dest, long long destLen) {
+	GRID_LOOP(tid, destLen) {
 		dest[tid] = powf(dest[tid], s);
 	}
 }
 
 extern "C" __global__
 void mapForward(float * dst, float * src, int * table, int tableSize) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < tableSize) {
+	GRID_LOOP(tid, tableSize) {
 		dst[tid] = src[table[tid]];
 	}
 }
 
 extern "C" __global__
 void mapBackward(float * dst, float * src, int * table, int tableSize) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < tableSize) {
+	GRID_LOOP(tid, tableSize) {
 		atomicAdd(&dst[table[tid]], src[tid]);
 	}
 }
 
 extern "C" __global__
 void mapMax(int * table, float * data, int rows, int cols) {
-	int tid = blockIdx.x * blockDim.x + threadIdx.x;
-	if (tid < rows) {
-		int base = tid * cols;
+	GRID_LOOP(tid, rows) {
+		long long base = tid * cols;
 		float * row = &data[base];
 		int maxIdx = 0;
 		float maxVal = row[0];
@@ -251,6 +239,614 @@ void mapMax(int * table, float * data, int rows, int cols) {
 				maxIdx = i;
 			}
 		}
-		table[tid] = maxIdx + base;
+		table[tid] = (int)(base + maxIdx);
+	}
+}
+
+extern "C" __global__
+void im2col(float * dst, float * src, int n, int inWidth, int inHeight,
+	int depth, int outWidth, int outHeight, int filterWidth, int filterHeight,
+	int strideX, int strideY, int padX, int padY) {
+	GRID_LOOP(tid, n) {
+		int patchSize = filterWidth * filterHeight * depth;
+		int row = tid / patchSize;
+		int col = tid % patchSize;
+		int z = col % depth;
+		int fx = (col / depth) % filterWidth;
+		int fy = col / (depth * filterWidth);
+		int outX = row % outWidth;
+		int outY = (row / outWidth) % outHeight;
+		int batch = row / (outWidth * outHeight);
+		int x = outX*strideX + fx - padX;
+		int y = outY*strideY + fy - padY;
+		if (x < 0 || y < 0 || x >= inWidth || y >= inHeight) {
+			dst[tid] = 0;
+		} else {
+			dst[tid] = src[((batch*inHeight+y)*inWidth+x)*depth+z];
+		}
+	}
+}
+
+extern "C" __global__
+void col2im(float * dst, float * src, int n, int inWidth, int inHeight,
+	int depth, int outWidth, int outHeight, int filterWidth, int filterHeight,
+	int strideX, int strideY, int padX, int padY) {
+	GRID_LOOP(tid, n) {
+		int patchSize = filterWidth * filterHeight * depth;
+		int row = tid / patchSize;
+		int col = tid % patchSize;
+		int z = col % depth;
+		int fx = (col / depth) % filterWidth;
+		int fy = col / (depth * filterWidth);
+		int outX = row % outWidth;
+		int outY = (row / outWidth) % outHeight;
+		int batch = row / (outWidth * outHeight);
+		int x = outX*strideX + fx - padX;
+		int y = outY*strideY + fy - padY;
+		if (x >= 0 && y >= 0 && x < inWidth && y < inHeight) {
+			atomicAdd(&dst[((batch*inHeight+y)*inWidth+x)*depth+z], src[tid]);
+		}
+	}
+}
+
+extern "C" __global__
+void maxPool(float * dst, int * table, float * src, int n, int inWidth,
+	int inHeight, int depth, int outWidth, int outHeight, int winWidth,
+	int winHeight, int strideX, int strideY, int padX, int padY) {
+	GRID_LOOP(tid, n) {
+		int z = tid % depth;
+		int outX = (tid / depth) % outWidth;
+		int outY = (tid / (depth * outWidth)) % outHeight;
+		int batch = tid / (depth * outWidth * outHeight);
+		int maxIdx = -1;
+		float maxVal = 0;
+		for (int wy = 0; wy < winHeight; ++wy) {
+			int y = outY*strideY + wy - padY;
+			if (y < 0 || y >= inHeight) {
+				continue;
+			}
+			for (int wx = 0; wx < winWidth; ++wx) {
+				int x = outX*strideX + wx - padX;
+				if (x < 0 || x >= inWidth) {
+					continue;
+				}
+				int idx = ((batch*inHeight+y)*inWidth+x)*depth + z;
+				if (maxIdx < 0 || src[idx] > maxVal) {
+					maxVal = src[idx];
+					maxIdx = idx;
+				}
+			}
+		}
+		dst[tid] = maxVal;
+		table[tid] = maxIdx;
+	}
+}
+
+extern "C" __global__
+void avgPool(float * dst, float * src, int n, int inWidth, int inHeight,
+	int depth, int outWidth, int outHeight, int winWidth, int winHeight,
+	int strideX, int strideY, int padX, int padY) {
+	GRID_LOOP(tid, n) {
+		int z = tid % depth;
+		int outX = (tid / depth) % outWidth;
+		int outY = (tid / (depth * outWidth)) % outHeight;
+		int batch = tid / (depth * outWidth * outHeight);
+		float sum = 0;
+		int count = 0;
+		for (int wy = 0; wy < winHeight; ++wy) {
+			int y = outY*strideY + wy - padY;
+			if (y < 0 || y >= inHeight) {
+				continue;
+			}
+			for (int wx = 0; wx < winWidth; ++wx) {
+				int x = outX*strideX + wx - padX;
+				if (x < 0 || x >= inWidth) {
+					continue;
+				}
+				sum += src[((batch*inHeight+y)*inWidth+x)*depth + z];
+				++count;
+			}
+		}
+		dst[tid] = sum / count;
+	}
+}
+
+extern "C" __global__
+void avgPoolBackward(float * dst, float * src, int n, int inWidth,
+	int inHeight, int depth, int outWidth, int outHeight, int winWidth,
+	int winHeight, int strideX, int strideY, int padX, int padY) {
+	GRID_LOOP(tid, n) {
+		int z = tid % depth;
+		int outX = (tid / depth) % outWidth;
+		int outY = (tid / (depth * outWidth)) % outHeight;
+		int batch = tid / (depth * outWidth * outHeight);
+		int minX = max(outX*strideX - padX, 0);
+		int minY = max(outY*strideY - padY, 0);
+		int maxX = min(outX*strideX - padX + winWidth, inWidth);
+		int maxY = min(outY*strideY - padY + winHeight, inHeight);
+		float grad = src[tid] / ((maxX - minX) * (maxY - minY));
+		for (int y = minY; y < maxY; ++y) {
+			for (int x = minX; x < maxX; ++x) {
+				atomicAdd(&dst[((batch*inHeight+y)*inWidth+x)*depth + z], grad);
+			}
+		}
+	}
+}
+
+// blockSum sums a value across all the threads in a block.
+// The block size must be a power of 2, and shared must
+// have room for one float per thread.
+extern "C" __device__
+float blockSum(float * shared, float value) {
+	shared[threadIdx.x] = value;
+	__syncthreads();
+	for (int stride = (blockDim.x>>1); stride >= 1; stride >>= 1) {
+		if (threadIdx.x < stride) {
+			shared[threadIdx.x] += shared[threadIdx.x+stride];
+		}
+		__syncthreads();
+	}
+	float res = shared[0];
+	__syncthreads();
+	return res;
+}
+
+extern "C" __global__
+void layerNorm(float * out, float * mean, float * invStd, float * in,
+	float * scale, float * shift, int rows, int cols, float epsilon) {
+	extern __shared__ float shared[];
+	for (int rowIdx = blockIdx.x; rowIdx < rows; rowIdx += gridDim.x) {
+		float * row = &in[(long long)rowIdx*cols];
+		float * outRow = &out[(long long)rowIdx*cols];
+
+		float sum = 0;
+		for (int i = threadIdx.x; i < cols; i += blockDim.x) {
+			sum += row[i];
+		}
+		float m = blockSum(shared, sum) / cols;
+
+		float sqSum = 0;
+		for (int i = threadIdx.x; i < cols; i += blockDim.x) {
+			float diff = row[i] - m;
+			sqSum += diff * diff;
+		}
+		float s = rsqrtf(blockSum(shared, sqSum)/cols + epsilon);
+
+		for (int i = threadIdx.x; i < cols; i += blockDim.x) {
+			outRow[i] = (row[i]-m)*s*scale[i] + shift[i];
+		}
+		if (threadIdx.x == 0) {
+			mean[rowIdx] = m;
+			invStd[rowIdx] = s;
+		}
+	}
+}
+
+extern "C" __global__
+void layerNormBackward(float * inGrad, float * outGrad, float * in,
+	float * scale, float * mean, float * invStd, int rows, int cols) {
+	extern __shared__ float shared[];
+	for (int rowIdx = blockIdx.x; rowIdx < rows; rowIdx += gridDim.x) {
+		long long offset = (long long)rowIdx * cols;
+		float m = mean[rowIdx];
+		float s = invStd[rowIdx];
+
+		float gradSum = 0;
+		float gradDot = 0;
+		for (int i = threadIdx.x; i < cols; i += blockDim.x) {
+			float g = outGrad[offset+i] * scale[i];
+			gradSum += g;
+			gradDot += g * (in[offset+i]-m) * s;
+		}
+		float a = blockSum(shared, gradSum) / cols;
+		float b = blockSum(shared, gradDot) / cols;
+
+		for (int i = threadIdx.x; i < cols; i += blockDim.x) {
+			float g = outGrad[offset+i] * scale[i];
+			float normed = (in[offset+i]-m) * s;
+			inGrad[offset+i] += s * (g - a - normed*b);
+		}
+	}
+}
+
+extern "C" __global__
+void layerNormParamGrads(float * scaleGrad, float * shiftGrad,
+	float * outGrad, float * in, float * mean, float * invStd, int rows,
+	int cols) {
+	GRID_LOOP(tid, cols) {
+		float scaleSum = 0;
+		float shiftSum = 0;
+		for (int i = 0; i < rows; ++i) {
+			float g = outGrad[(long long)i*cols+tid];
+			scaleSum += g * (in[(long long)i*cols+tid]-mean[i]) * invStd[i];
+			shiftSum += g;
+		}
+		scaleGrad[tid] += scaleSum;
+		shiftGrad[tid] += shiftSum;
+	}
+}
+
+extern "C" __global__
+void batchNorm(float * out, float * mean, float * invStd, float * in,
+	float * scale, float * shift, int rows, int cols, float epsilon) {
+	GRID_LOOP(tid, cols) {
+		float sum = 0;
+		for (int i = 0; i < rows; ++i) {
+			sum += in[(long long)i*cols+tid];
+		}
+		float m = sum / rows;
+		float sqSum = 0;
+		for (int i = 0; i < rows; ++i) {
+			float diff = in[(long long)i*cols+tid] - m;
+			sqSum += diff * diff;
+		}
+		float s = rsqrtf(sqSum/rows + epsilon);
+		for (int i = 0; i < rows; ++i) {
+			out[(long long)i*cols+tid] = (in[(long long)i*cols+tid]-m)*s*scale[tid] + shift[tid];
+		}
+		mean[tid] = m;
+		invStd[tid] = s;
+	}
+}
+
+extern "C" __global__
+void batchNormBackward(float * inGrad, float * scaleGrad, float * shiftGrad,
+	float * outGrad, float * in, float * scale, float * mean, float * invStd,
+	int rows, int cols) {
+	GRID_LOOP(tid, cols) {
+		float m = mean[tid];
+		float s = invStd[tid];
+		float gradSum = 0;
+		float gradDot = 0;
+		for (int i = 0; i < rows; ++i) {
+			float g = outGrad[(long long)i*cols+tid];
+			gradSum += g;
+			gradDot += g * (in[(long long)i*cols+tid]-m) * s;
+		}
+		float a = scale[tid] * gradSum / rows;
+		float b = scale[tid] * gradDot / rows;
+		for (int i = 0; i < rows; ++i) {
+			float normed = (in[(long long)i*cols+tid]-m) * s;
+			float g = outGrad[(long long)i*cols+tid] * scale[tid];
+			inGrad[(long long)i*cols+tid] += s * (g - a - normed*b);
+		}
+		scaleGrad[tid] += gradDot;
+		shiftGrad[tid] += gradSum;
+	}
+}
+
+// blockMax is like blockSum, but it computes a maximum.
+extern "C" __device__
+float blockMax(float * shared, float value) {
+	shared[threadIdx.x] = value;
+	__syncthreads();
+	for (int stride = (blockDim.x>>1); stride >= 1; stride >>= 1) {
+		if (threadIdx.x < stride) {
+			shared[threadIdx.x] = fmaxf(shared[threadIdx.x],
+				shared[threadIdx.x+stride]);
+		}
+		__syncthreads();
+	}
+	float res = shared[0];
+	__syncthreads();
+	return res;
+}
+
+// attentionForwardRow computes the output for one query,
+// processing keys in tiles of blockDim.x with an online
+// softmax so that the score matrix is never stored.
+extern "C" __device__
+void attentionForwardRow(float * out, float * logSumExp, float * q, float * k,
+	float * v, long long head, int query, int seqLen, int dim, float scale,
+	int causal) {
+	extern __shared__ float shared[];
+	float * probs = shared;
+	float * reduce = &shared[blockDim.x];
+
+	long long rowIdx = head*seqLen + query;
+	long long headOffset = head*seqLen*dim;
+	float * qRow = &q[rowIdx*dim];
+	float * outRow = &out[rowIdx*dim];
+	float * keys = &k[headOffset];
+	float * values = &v[headOffset];
+	int numKeys = causal ? query+1 : seqLen;
+
+	for (int d = threadIdx.x; d < dim; d += blockDim.x) {
+		outRow[d] = 0;
+	}
+	float runningMax = -INFINITY;
+	float runningSum = 0;
+	for (int tile = 0; tile < numKeys; tile += blockDim.x) {
+		int key = tile + threadIdx.x;
+		float score = -INFINITY;
+		if (key < numKeys) {
+			score = 0;
+			for (int d = 0; d < dim; ++d) {
+				score += qRow[d] * keys[(long long)key*dim+d];
+			}
+			score *= scale;
+		}
+		float newMax = fmaxf(runningMax, blockMax(reduce, score));
+		float prob = 0;
+		if (key < numKeys) {
+			prob = expf(score - newMax);
+		}
+		probs[threadIdx.x] = prob;
+		float correction = expf(runningMax - newMax);
+		runningSum = runningSum*correction + blockSum(reduce, prob);
+		runningMax = newMax;
+
+		int tileSize = min((int)blockDim.x, numKeys-tile);
+		for (int d = threadIdx.x; d < dim; d += blockDim.x) {
+			float acc = outRow[d] * correction;
+			for (int j = 0; j < tileSize; ++j) {
+				acc += probs[j] * values[(long long)(tile+j)*dim+d];
+			}
+			outRow[d] = acc;
+		}
+		__syncthreads();
+	}
+
+	for (int d = threadIdx.x; d < dim; d += blockDim.x) {
+		outRow[d] /= runningSum;
+	}
+	if (threadIdx.x == 0) {
+		logSumExp[rowIdx] = runningMax + logf(runningSum);
+	}
+}
+
+// attentionForward loops over the queries along the x
+// dimension of the grid and over the heads along the y
+// dimension, so that a grid of any size covers them all.
+extern "C" __global__
+void attentionForward(float * out, float * logSumExp, float * q, float * k,
+	float * v, long long numHeads, int seqLen, int dim, float scale, int causal) {
+	for (long long head = blockIdx.y; head < numHeads; head += gridDim.y) {
+		for (int query = blockIdx.x; query < seqLen; query += gridDim.x) {
+			attentionForwardRow(out, logSumExp, q, k, v, head, query, seqLen, dim,
+				scale, causal);
+		}
+	}
+}
+
+extern "C" __device__
+void attentionBackwardRow(float * qGrad, float * kGrad, float * vGrad,
+	float * outGrad, float * out, float * logSumExp, float * q, float * k,
+	float * v, long long head, int query, int seqLen, int dim, float scale,
+	int causal) {
+	extern __shared__ float shared[];
+	float * probs = shared;
+	float * scoreGrads = &shared[blockDim.x];
+	float * reduce = &shared[2*blockDim.x];
+
+	long long rowIdx = head*seqLen + query;
+	long long headOffset = head*seqLen*dim;
+	float * qRow = &q[rowIdx*dim];
+	float * qGradRow = &qGrad[rowIdx*dim];
+	float * outRow = &out[rowIdx*dim];
+	float * outGradRow = &outGrad[rowIdx*dim];
+	int numKeys = causal ? query+1 : seqLen;
+
+	float dot = 0;
+	for (int d = threadIdx.x; d < dim; d += blockDim.x) {
+		dot += outGradRow[d] * outRow[d];
+	}
+	float delta = blockSum(reduce, dot);
+	float lse = logSumExp[rowIdx];
+
+	for (int tile = 0; tile < numKeys; tile += blockDim.x) {
+		int key = tile + threadIdx.x;
+		float prob = 0;
+		float scoreGrad = 0;
+		if (key < numKeys) {
+			float score = 0;
+			float probGrad = 0;
+			for (int d = 0; d < dim; ++d) {
+				long long idx = headOffset + (long long)key*dim + d;
+				score += qRow[d] * k[idx];
+				probGrad += outGradRow[d] * v[idx];
+			}
+			prob = expf(score*scale - lse);
+			scoreGrad = prob * (probGrad - delta);
+		}
+		probs[threadIdx.x] = prob;
+		scoreGrads[threadIdx.x] = scoreGrad;
+		__syncthreads();
+
+		int tileSize = min((int)blockDim.x, numKeys-tile);
+		for (int d = threadIdx.x; d < dim; d += blockDim.x) {
+			float acc = 0;
+			for (int j = 0; j < tileSize; ++j) {
+				long long idx = headOffset + (long long)(tile+j)*dim + d;
+				acc += scoreGrads[j] * k[idx];
+				atomicAdd(&kGrad[idx], scale*scoreGrads[j]*qRow[d]);
+				atomicAdd(&vGrad[idx], probs[j]*outGradRow[d]);
+			}
+			qGradRow[d] += scale * acc;
+		}
+		__syncthreads();
+	}
+}
+
+// attentionBackward loops over queries and heads like
+// attentionForward.
+extern "C" __global__
+void attentionBackward(float * qGrad, float * kGrad, float * vGrad,
+	float * outGrad, float * out, float * logSumExp, float * q, float * k,
+	float * v, long long numHeads, int seqLen, int dim, float scale, int causal) {
+	for (long long head = blockIdx.y; head < numHeads; head += gridDim.y) {
+		for (int query = blockIdx.x; query < seqLen; query += gridDim.x) {
+			attentionBackwardRow(qGrad, kGrad, vGrad, outGrad, out, logSumExp, q, k,
+				v, head, query, seqLen, dim, scale, causal);
+		}
+	}
+}
+
+extern "C" __global__
+void gather(float * dst, float * src, int * indices, int n, int srcLen) {
+	GRID_LOOP(tid, n) {
+		int idx = indices[tid];
+		if (idx >= 0 && idx < srcLen) {
+			dst[tid] = src[idx];
+		} else {
+			dst[tid] = 0;
+		}
+	}
+}
+
+extern "C" __global__
+void scatterAdd(float * dst, float * src, int * indices, int n, int dstLen) {
+	GRID_LOOP(tid, n) {
+		int idx = indices[tid];
+		if (idx >= 0 && idx < dstLen) {
+			atomicAdd(&dst[idx], src[tid]);
+		}
+	}
+}
+
+extern "C" __device__
+void atomicMaxFloat(float * addr, float value) {
+	int * intAddr = (int *)addr;
+	int old = *intAddr;
+	while (__int_as_float(old) < value) {
+		int assumed = old;
+		old = atomicCAS(intAddr, assumed, __float_as_int(value));
+		if (old == assumed) {
+			break;
+		}
+	}
+}
+
+extern "C" __global__
+void scatterMax(float * dst, float * src, int * indices, int n, int dstLen) {
+	GRID_LOOP(tid, n) {
+		int idx = indices[tid];
+		if (idx >= 0 && idx < dstLen) {
+			atomicMaxFloat(&dst[idx], src[tid]);
+		}
+	}
+}
+
+extern "C" __global__
+void countOutOfRange(int * count, int * indices, int n, int limit) {
+	GRID_LOOP(tid, n) {
+		if (indices[tid] < 0 || indices[tid] >= limit) {
+			atomicAdd(count, 1);
+		}
+	}
+}
+
+extern "C" __global__
+void addInts(int * x, int * y, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] += y[tid];
+	}
+}
+
+extern "C" __global__
+void subInts(int * x, int * y, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] -= y[tid];
+	}
+}
+
+extern "C" __global__
+void mulInts(int * x, int * y, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] *= y[tid];
+	}
+}
+
+extern "C" __global__
+void divInts(int * x, int * y, int n) {
+	GRID_LOOP(tid, n) {
+		if (y[tid] == 0) {
+			x[tid] = 0;
+		} else {
+			x[tid] /= y[tid];
+		}
+	}
+}
+
+extern "C" __global__
+void addScalerInt(int s, int * x, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] += s;
+	}
+}
+
+extern "C" __global__
+void scaleInt(int s, int * x, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] *= s;
+	}
+}
+
+extern "C" __global__
+void lessThanInt(int s, int * x, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] = (x[tid] < s);
+	}
+}
+
+extern "C" __global__
+void greaterThanInt(int s, int * x, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] = (x[tid] > s);
+	}
+}
+
+extern "C" __global__
+void equalToInt(int s, int * x, int n) {
+	GRID_LOOP(tid, n) {
+		x[tid] = (x[tid] == s);
+	}
+}
+
+extern "C" __global__
+void intToFloat(float * dst, int * src, int n) {
+	GRID_LOOP(tid, n) {
+		dst[tid] = (float)src[tid];
+	}
+}
+
+extern "C" __global__
+void floatToInt(int * dst, float * src, int n) {
+	GRID_LOOP(tid, n) {
+		dst[tid] = __float2int_rn(src[tid]);
+	}
+}
+
+extern "C" __global__
+void argMax(int * dst, float * data, int rows, int cols) {
+	GRID_LOOP(tid, rows) {
+		float * row = &data[tid * cols];
+		int maxIdx = 0;
+		float maxVal = row[0];
+		for (int i = 1; i < cols; ++i) {
+			if (row[i] > maxVal) {
+				maxVal = row[i];
+				maxIdx = i;
+			}
+		}
+		dst[tid] = maxIdx;
+	}
+}
+
+extern "C" __global__
+void composeTables(int * dst, int * first, int * second, int n) {
+	GRID_LOOP(tid, n) {
+		dst[tid] = first[second[tid]];
+	}
+}
+
+extern "C" __global__
+void countNonFinite(int * count, float * x, long long n) {
+	int bad = 0;
+	GRID_LOOP(tid, n) {
+		bad += !isfinite(x[tid]);
+	}
+	if (bad > 0) {
+		atomicAdd(count, bad);
 	}
 }
@@ -0,0 +1,388 @@
+package cudavec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/cuda"
+	"github.com/unixpickle/cudavec/internal/kernelsig"
+)
+
+// launchFixedArgs maps each Handle method that launches a
+// kernel to the number of its arguments which are not
+// passed to the kernel.
+var launchFixedArgs = map[string]int{
+	"launch":     9,
+	"launchFlat": 2,
+	"launchRows": 3,
+}
+
+func TestKernelManifest(t *testing.T) {
+	manifest, err := readKernelManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := ioutil.ReadFile(manifest.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kernels, err := kernelsig.Parse(string(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kernels, manifest.Kernels) {
+		t.Error("manifest does not match kernel source (run go generate)")
+	}
+	paths, err := fs.Glob(kernelFiles, "kernels/*.ptx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != len(manifest.Archs) {
+		t.Errorf("expected %d PTX files but got %d (run go generate)", len(manifest.Archs),
+			len(paths))
+	}
+	for _, arch := range manifest.Archs {
+		ptx, err := kernelPTX(arch)
+		if err != nil {
+			t.Errorf("arch %s: %s", arch, err)
+			continue
+		}
+		if err := kernelsig.CheckPTX(manifest.Kernels, ptx); err != nil {
+			t.Errorf("arch %s: %s (run go generate)", arch, err)
+		}
+	}
+}
+
+// TestKernelLaunches checks that every kernel launched by
+// the package exists and is passed the right number of
+// arguments.
+//
+// Kernel names that are parameters of a wrapper function
+// are checked at the call sites of the wrapper.
+func TestKernelLaunches(t *testing.T) {
+	manifest, err := readKernelManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := parsePackageFiles(t)
+
+	checkLaunch := func(pos token.Position, lit ast.Expr, numArgs int) {
+		name, ok := stringLiteral(lit)
+		if !ok {
+			t.Errorf("%s: cannot resolve kernel name", pos)
+			return
+		}
+		kernel := manifest.Kernel(name)
+		if kernel == nil {
+			t.Errorf("%s: unknown kernel %s", pos, name)
+		} else if numArgs >= 0 && numArgs != len(kernel.Params) {
+			t.Errorf("%s: kernel %s takes %d arguments but got %d", pos, name,
+				len(kernel.Params), numArgs)
+		}
+	}
+
+	type wrapper struct {
+		nameIndex int
+		numArgs   int
+	}
+	wrappers := map[string]*wrapper{}
+	var numLaunches int
+	for _, file := range files.files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, fixed := handleLaunch(fn, n)
+				if call == nil {
+					return true
+				}
+				numLaunches++
+				numArgs := len(call.Args) - fixed
+				if call.Ellipsis.IsValid() {
+					numArgs = -1
+				}
+				if ident, ok := call.Args[0].(*ast.Ident); ok {
+					if idx := paramIndex(fn, ident.Name); idx >= 0 {
+						wrappers[funcKey(fn)] = &wrapper{nameIndex: idx, numArgs: numArgs}
+						return true
+					}
+				}
+				checkLaunch(files.fset.Position(call.Pos()), call.Args[0], numArgs)
+				return true
+			})
+		}
+	}
+	if numLaunches == 0 {
+		t.Fatal("no kernel launches found")
+	}
+
+	// Functions that forward a kernel name to a wrapper are
+	// wrappers too, although their argument counts are not
+	// known.
+	forEachWrapperCall := func(f func(fn *ast.FuncDecl, call *ast.CallExpr, w *wrapper)) {
+		for _, file := range files.files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					w := wrappers[calleeKey(fn, call)]
+					if w != nil && w.nameIndex < len(call.Args) {
+						f(fn, call, w)
+					}
+					return true
+				})
+			}
+		}
+	}
+	for {
+		numWrappers := len(wrappers)
+		forEachWrapperCall(func(fn *ast.FuncDecl, call *ast.CallExpr, w *wrapper) {
+			ident, ok := call.Args[w.nameIndex].(*ast.Ident)
+			if !ok || wrappers[funcKey(fn)] != nil {
+				return
+			}
+			if idx := paramIndex(fn, ident.Name); idx >= 0 {
+				wrappers[funcKey(fn)] = &wrapper{nameIndex: idx, numArgs: -1}
+			}
+		})
+		if len(wrappers) == numWrappers {
+			break
+		}
+	}
+	forEachWrapperCall(func(fn *ast.FuncDecl, call *ast.CallExpr, w *wrapper) {
+		arg := call.Args[w.nameIndex]
+		if ident, ok := arg.(*ast.Ident); ok && paramIndex(fn, ident.Name) >= 0 {
+			return
+		}
+		checkLaunch(files.fset.Position(call.Pos()), arg, w.numArgs)
+	})
+}
+
+type packageFiles struct {
+	fset  *token.FileSet
+	files []*ast.File
+}
+
+func parsePackageFiles(t *testing.T) *packageFiles {
+	res := &packageFiles{fset: token.NewFileSet()}
+	infos, err := ioutil.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(res.fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.files = append(res.files, file)
+	}
+	return res
+}
+
+// handleLaunch checks if a node is a call to one of the
+// Handle's launch methods.
+func handleLaunch(fn *ast.FuncDecl, n ast.Node) (*ast.CallExpr, int) {
+	call, ok := n.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return nil, 0
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, 0
+	}
+	fixed, ok := launchFixedArgs[sel.Sel.Name]
+	if !ok {
+		return nil, 0
+	}
+	switch x := sel.X.(type) {
+	case *ast.SelectorExpr:
+		if x.Sel.Name == "Handle" {
+			return call, fixed
+		}
+	case *ast.Ident:
+		if x.Name == "h" || (x.Name == receiverName(fn) && receiverType(fn) == "Handle") {
+			return call, fixed
+		}
+	}
+	return nil, 0
+}
+
+// funcKey identifies a function or method declaration.
+func funcKey(fn *ast.FuncDecl) string {
+	return receiverType(fn) + "." + fn.Name.Name
+}
+
+// calleeKey finds the funcKey of a called function.
+//
+// Methods are only resolved when they are called on the
+// receiver of the calling method.
+func calleeKey(caller *ast.FuncDecl, call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return "." + fun.Name
+	case *ast.SelectorExpr:
+		if x, ok := fun.X.(*ast.Ident); ok && x.Name == receiverName(caller) {
+			return receiverType(caller) + "." + fun.Sel.Name
+		}
+	}
+	return ""
+}
+
+func receiverName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List[0].Names) == 0 {
+		return ""
+	}
+	return fn.Recv.List[0].Names[0].Name
+}
+
+func receiverType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil {
+		return ""
+	}
+	typ := fn.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// paramIndex finds the argument index of a parameter, or
+// returns -1 if there is no such parameter.
+func paramIndex(fn *ast.FuncDecl, name string) int {
+	var idx int
+	for _, field := range fn.Type.Params.List {
+		for _, ident := range field.Names {
+			if ident.Name == name {
+				return idx
+			}
+			idx++
+		}
+	}
+	return -1
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	res, err := strconv.Unquote(lit.Value)
+	return res, err == nil
+}
+
+func TestSelectArchs(t *testing.T) {
+	archs := []string{"compute_35", "compute_80", "compute_50", "compute_60", "bad",
+		"compute_70", "compute_100"}
+	tests := []struct {
+		cc       computeCapability
+		expected []string
+	}{
+		{computeCapability{3, 0}, []string{}},
+		{computeCapability{3, 5}, []string{"compute_35"}},
+		{computeCapability{5, 2}, []string{"compute_50", "compute_35"}},
+		{computeCapability{7, 5}, []string{"compute_70", "compute_60", "compute_50",
+			"compute_35"}},
+		{computeCapability{8, 6}, []string{"compute_80", "compute_70", "compute_60",
+			"compute_50", "compute_35"}},
+		{computeCapability{12, 0}, []string{"compute_100", "compute_80", "compute_70",
+			"compute_60", "compute_50", "compute_35"}},
+	}
+	for _, test := range tests {
+		actual := selectArchs(archs, test.cc)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("capability %v: expected %v but got %v", test.cc, test.expected,
+				actual)
+		}
+	}
+}
+
+func TestSelectEmbeddedArchs(t *testing.T) {
+	manifest, err := readKernelManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cc    computeCapability
+		first string
+	}{
+		{computeCapability{3, 5}, "compute_35"},
+		{computeCapability{5, 2}, "compute_50"},
+		{computeCapability{6, 1}, "compute_60"},
+		{computeCapability{7, 5}, "compute_70"},
+		{computeCapability{8, 6}, "compute_80"},
+		{computeCapability{9, 0}, "compute_80"},
+	}
+	for _, test := range tests {
+		archs := selectArchs(manifest.Archs, test.cc)
+		if len(archs) == 0 || archs[0] != test.first {
+			t.Errorf("capability %v: expected %s first but got %v", test.cc, test.first,
+				archs)
+			continue
+		}
+		for _, arch := range archs {
+			ptx, err := kernelPTX(arch)
+			if err != nil {
+				t.Errorf("arch %s: %s", arch, err)
+			} else if !strings.Contains(ptx, ".target sm_"+strings.TrimPrefix(arch,
+				"compute_")) {
+				t.Errorf("arch %s: PTX has the wrong target", arch)
+			}
+		}
+	}
+	if archs := selectArchs(manifest.Archs, computeCapability{3, 0}); len(archs) != 0 {
+		t.Errorf("unexpected archs for capability 3.0: %v", archs)
+	}
+}
+
+func TestNewHandleContext(t *testing.T) {
+	devs, err := cuda.AllDevices()
+	if err != nil || len(devs) == 0 {
+		t.Skip("no CUDA device available")
+	}
+	ctx, err := cuda.NewContext(devs[0], -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandle(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := devs[0].Name()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.deviceName != name {
+		t.Errorf("expected device %q but got %q", name, h.deviceName)
+	}
+	if h.maxGrid < defaultMaxGrid {
+		t.Errorf("unexpected max grid: %d", h.maxGrid)
+	}
+	c := &Creator32{Handle: h}
+	vec := c.MakeVectorData([]float32{1, 2, 3})
+	vec.AddScalar(float32(1))
+	if data := vec.Data().([]float32); data[0] != 2 || data[2] != 4 {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
@@ -0,0 +1,121 @@
+package cudavec
+
+import "github.com/unixpickle/cuda"
+
+const (
+	// defaultBlockSize is the block size used for
+	// elementwise kernels.
+	defaultBlockSize = 128
+
+	// defaultMaxGrid is the largest grid dimension that
+	// every device supports.
+	// It is always the limit for the y and z dimensions.
+	defaultMaxGrid = 65535
+)
+
+// launchFlat launches a kernel that loops over n elements
+// with a grid-stride loop.
+//
+// The block size comes from the tuner if there is one.
+func (h *Handle) launchFlat(name string, n int, args ...interface{}) error {
+	return h.launchTuned(tuneKey(name, n), name, n, args)
+}
+
+// launchRows is like launchFlat for kernels that process
+// one row of cols elements per thread.
+//
+// Since the work per thread depends on cols, it is tuned
+// separately for every column bucket.
+func (h *Handle) launchRows(name string, rows, cols int, args ...interface{}) error {
+	return h.launchTuned(tuneKey(name, rows, cols), name, rows, args)
+}
+
+func (h *Handle) launchTuned(key, name string, n int, args []interface{}) error {
+	block, err := h.tunedBlock(key, defaultBlockSize, flatCandidates,
+		func() (tuneBench, error) {
+			return h.flatBench(name, n, args)
+		})
+	if err != nil {
+		return err
+	}
+	grid, block := launchSizes(n, block, h.maxGrid)
+	return h.launch(name, grid, 1, 1, block, 1, 1, 0, nil, args...)
+}
+
+// flatBench creates a benchmark for a kernel that uses a
+// grid-stride loop.
+//
+// The benchmark runs on copies of every buffer, so that
+// the kernel can be repeated without changing the real
+// outputs.
+// The copies are not scratch buffers, so they are not kept
+// by the scratch arena once tuning is done.
+// If the buffers are larger than maxTuneBytes in total, no
+// benchmark is created.
+func (h *Handle) flatBench(name string, n int, args []interface{}) (tuneBench, error) {
+	var totalBytes uintptr
+	for _, arg := range args {
+		if buf, ok := arg.(cuda.Buffer); ok {
+			totalBytes += buf.Size()
+		}
+	}
+	if totalBytes > maxTuneBytes {
+		return nil, nil
+	}
+	benchArgs := make([]interface{}, len(args))
+	for i, arg := range args {
+		buf, ok := arg.(cuda.Buffer)
+		if !ok {
+			benchArgs[i] = arg
+			continue
+		}
+		copied, err := h.allocBuffer(SiteTemp, buf.Size())
+		if err != nil {
+			return nil, err
+		}
+		if err := cuda.CopyBuffer(copied, buf); err != nil {
+			return nil, err
+		}
+		benchArgs[i] = copied
+	}
+	return func(block uint) error {
+		grid, block := launchSizes(n, block, h.maxGrid)
+		return h.launch(name, grid, 1, 1, block, 1, 1, 0, nil, benchArgs...)
+	}, nil
+}
+
+// launchSizes computes the launch dimensions for a kernel
+// that uses a grid-stride loop over n elements.
+//
+// The block is shrunk for small n, and the grid is capped
+// at maxGrid blocks.
+// Since the kernels loop, a capped grid still covers every
+// element.
+func launchSizes(n int, block, maxGrid uint) (gridSize, blockSize uint) {
+	if n <= 0 {
+		return 1, 1
+	}
+	if uint64(n) < uint64(block) {
+		return 1, uint(n)
+	}
+	return capGrid(ceilDiv(n, int(block)), maxGrid), block
+}
+
+// capGrid limits a grid dimension to maxGrid.
+// The result is always at least 1.
+func capGrid(n int, maxGrid uint) uint {
+	if n <= 1 {
+		return 1
+	} else if uint64(n) > uint64(maxGrid) {
+		return maxGrid
+	}
+	return uint(n)
+}
+
+func ceilDiv(x, y int) int {
+	res := x / y
+	if x%y != 0 {
+		res++
+	}
+	return res
+}
@@ -0,0 +1,187 @@
+package cudavec
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/cuda"
+)
+
+func TestLaunchSizes(t *testing.T) {
+	const maxGrid = 65535
+	tests := []struct {
+		n     int
+		grid  uint
+		block uint
+	}{
+		{0, 1, 1},
+		{1, 1, 1},
+		{127, 1, 127},
+		{128, 1, 128},
+		{129, 2, 128},
+		{256, 2, 128},
+		{maxGrid * 128, maxGrid, 128},
+		{maxGrid*128 + 1, maxGrid, 128},
+		{1 << 31, maxGrid, 128},
+		{1<<40 + 3, maxGrid, 128},
+	}
+	for _, test := range tests {
+		grid, block := launchSizes(test.n, 128, maxGrid)
+		if grid != test.grid || block != test.block {
+			t.Errorf("n=%d: expected (%d, %d) but got (%d, %d)", test.n,
+				test.grid, test.block, grid, block)
+		}
+	}
+}
+
+func TestLaunchSizesCoverage(t *testing.T) {
+	// A grid-stride loop must visit every index exactly
+	// once, even when the grid is capped.
+	for _, maxGrid := range []uint{1, 3, 7} {
+		for n := 0; n < 100; n++ {
+			grid, block := launchSizes(n, 4, maxGrid)
+			if grid > maxGrid {
+				t.Fatalf("grid %d exceeds %d", grid, maxGrid)
+			}
+			visits := make([]int, n)
+			stride := int(grid * block)
+			for start := 0; start < stride; start++ {
+				for i := start; i < n; i += stride {
+					visits[i]++
+				}
+			}
+			for i, count := range visits {
+				if count != 1 {
+					t.Fatalf("n=%d maxGrid=%d: index %d visited %d times", n,
+						maxGrid, i, count)
+				}
+			}
+		}
+	}
+}
+
+func TestCapGrid(t *testing.T) {
+	tests := []struct {
+		n       int
+		maxGrid uint
+		res     uint
+	}{
+		{-1, 10, 1},
+		{0, 10, 1},
+		{1, 10, 1},
+		{10, 10, 10},
+		{11, 10, 10},
+		{1 << 40, 1<<31 - 1, 1<<31 - 1},
+	}
+	for _, test := range tests {
+		if res := capGrid(test.n, test.maxGrid); res != test.res {
+			t.Errorf("capGrid(%d, %d): expected %d but got %d", test.n,
+				test.maxGrid, test.res, res)
+		}
+	}
+}
+
+func TestGridStrideLaunch(t *testing.T) {
+	h := setupDeviceTest(t)
+	c := &Creator32{Handle: h}
+	c32 := anyvec32.DefaultCreator{}
+
+	// With a tiny grid, every thread must loop over many
+	// elements.
+	defer setMaxGrid(h, setMaxGrid(h, 3))
+
+	data1 := randomSlice(10007)
+	data2 := randomSlice(10007)
+	for i := range data2 {
+		data2[i] += 2
+	}
+	vec1, vec2 := c.MakeVectorData(data1), c.MakeVectorData(data2)
+	exp1, exp2 := c32.MakeVectorData(data1), c32.MakeVectorData(data2)
+	vec1.AddScalar(float32(1.5))
+	exp1.AddScalar(float32(1.5))
+	vec1.Div(vec2)
+	exp1.Div(exp2)
+	vec1.Mul(vec2)
+	exp1.Mul(exp2)
+
+	actual := vec1.Data().([]float32)
+	expected := exp1.Data().([]float32)
+	for i, x := range expected {
+		if diff := actual[i] - x; diff < -1e-3 || diff > 1e-3 {
+			t.Fatalf("index %d: expected %f but got %f", i, x, actual[i])
+		}
+	}
+}
+
+// TestLaunchLarge runs a kernel over more elements than a
+// 32-bit index can address.
+func TestLaunchLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large allocation in short mode")
+	}
+	h := setupDeviceTest(t)
+	const n = 1<<31 + 1000
+
+	var buf cuda.Buffer
+	var allocErr error
+	<-h.context.Run(func() error {
+		buf, allocErr = h.allocBuffer(SiteTemp, n*4)
+		if allocErr == nil {
+			allocErr = cuda.ClearBuffer(buf)
+		}
+		return nil
+	})
+	if allocErr != nil {
+		t.Skipf("cannot allocate %d floats: %s", n, allocErr)
+	}
+	defer func() {
+		buf = nil
+		drainFinalizers()
+	}()
+
+	// The first launch may cover the buffer with one grid,
+	// while the second must loop.
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			defer setMaxGrid(h, setMaxGrid(h, 1024))
+		}
+		err := <-h.context.Run(func() error {
+			maxGrid := h.maxGrid
+			grid, block := launchSizes(n, defaultBlockSize, maxGrid)
+			err := h.launch("addScaler", grid, 1, 1, block, 1, 1, 0, nil, float32(1), buf,
+				int64(n))
+			if err != nil {
+				return err
+			}
+			for _, start := range []int{0, 1<<31 - 4, n - 4} {
+				res := make([]float32, 4)
+				part := cuda.Slice(buf, uintptr(start)*4, uintptr(start+4)*4)
+				if err := cuda.ReadBuffer(res, part); err != nil {
+					return err
+				}
+				for j, x := range res {
+					if x != float32(i+1) {
+						t.Errorf("maxGrid=%d: index %d: expected %d but got %f", maxGrid,
+							start+j, i+1, x)
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// setMaxGrid changes the Handle's grid limit and returns
+// the old limit.
+func setMaxGrid(h *Handle, maxGrid uint) uint {
+	var old uint
+	<-h.context.Run(func() error {
+		old = h.maxGrid
+		h.maxGrid = maxGrid
+		return nil
+	})
+	return old
+}
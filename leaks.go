@@ -0,0 +1,198 @@
+package cudavec
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxStackDepth is the number of frames recorded for each
+// allocation while tracing.
+const maxStackDepth = 32
+
+// An AllocHolder is a stack trace which is responsible
+// for live device buffers.
+type AllocHolder struct {
+	// Stack is the formatted stack trace.
+	//
+	// For buffers allocated by a queued operation, this
+	// is the stack of the code that queued it.
+	Stack string
+
+	Bytes   uint64
+	Buffers int
+}
+
+// SetAllocTracing enables or disables the recording of
+// stack traces for new allocations.
+//
+// Tracing makes every operation more expensive, so it
+// should only be used for debugging.
+// Buffers allocated while tracing is disabled have no
+// stack trace.
+func (h *Handle) SetAllocTracing(enabled bool) {
+	var flag int32
+	if enabled {
+		flag = 1
+	}
+	atomic.StoreInt32(&h.stats.tracing, flag)
+}
+
+// TopHolders returns the stack traces which hold the most
+// live device memory, sorted by size.
+//
+// At most n holders are returned.
+// Buffers allocated without tracing are grouped under an
+// empty stack.
+func (h *Handle) TopHolders(n int) []*AllocHolder {
+	return h.stats.TopHolders(n, 0, nil)
+}
+
+// LeakCheck runs f and returns an error if it left any
+// device buffers allocated.
+//
+// Before checking, the garbage collector is run and the
+// scratch buffers are dropped, so only buffers that are
+// still referenced count as leaks.
+// Tables in the mapper cache are kept, and they do not
+// count as leaks.
+// Allocation tracing is enabled while f runs, so the
+// error describes where the leaked buffers came from.
+//
+// LeakCheck must not be called while other goroutines are
+// using the Handle.
+func (h *Handle) LeakCheck(f func()) error {
+	wasTracing := h.stats.Tracing()
+	h.SetAllocTracing(true)
+	defer h.SetAllocTracing(wasTracing)
+
+	h.collectGarbage()
+	before := h.stats.Stats().Allocs
+	f()
+	h.collectGarbage()
+
+	holders := h.stats.TopHolders(-1, before, h.cachedTables())
+	if len(holders) == 0 {
+		return nil
+	}
+	var total uint64
+	var msg bytes.Buffer
+	for _, holder := range holders {
+		total += holder.Bytes
+		fmt.Fprintf(&msg, "\n%d bytes in %d buffers from:\n%s", holder.Bytes,
+			holder.Buffers, holder.Stack)
+	}
+	return fmt.Errorf("leak check: %d bytes leaked%s", total, msg.String())
+}
+
+// cachedTables finds the device pointers of the tables in
+// the mapper cache.
+func (h *Handle) cachedTables() map[unsafe.Pointer]bool {
+	res := map[unsafe.Pointer]bool{}
+	mappers := h.mapperCache.Mappers()
+	<-h.context.Run(func() error {
+		for _, m := range mappers {
+			if m.table != nil {
+				m.table.WithPtr(func(ptr unsafe.Pointer) {
+					res[ptr] = true
+				})
+			}
+		}
+		return nil
+	})
+	return res
+}
+
+// collectGarbage frees every unreferenced buffer.
+func (h *Handle) collectGarbage() {
+	h.ClearScratch()
+
+	// Finalizers may queue frees on the context, so wait
+	// for the context after running them.
+	for i := 0; i < 2; i++ {
+		drainFinalizers()
+		<-h.context.Run(func() error {
+			return nil
+		})
+	}
+}
+
+// Tracing checks if stack traces are being recorded.
+func (s *statsAllocator) Tracing() bool {
+	return atomic.LoadInt32(&s.tracing) != 0
+}
+
+// SetStack sets the stack trace recorded for future
+// allocations, overriding the allocation's own stack.
+func (s *statsAllocator) SetStack(stack []uintptr) {
+	s.lock.Lock()
+	s.stack = stack
+	s.lock.Unlock()
+}
+
+// TopHolders groups the live buffers by stack trace.
+//
+// Only buffers allocated after the first minAlloc
+// allocations are included, and buffers in ignore are
+// skipped.
+// If n is negative, all of the holders are returned.
+func (s *statsAllocator) TopHolders(n int, minAlloc uint64,
+	ignore map[unsafe.Pointer]bool) []*AllocHolder {
+	s.lock.Lock()
+	holders := map[string]*AllocHolder{}
+	for ptr, alloc := range s.live {
+		if alloc.id <= minAlloc || ignore[ptr] {
+			continue
+		}
+		stack := formatStack(alloc.stack)
+		holder, ok := holders[stack]
+		if !ok {
+			holder = &AllocHolder{Stack: stack}
+			holders[stack] = holder
+		}
+		holder.Bytes += uint64(alloc.size)
+		holder.Buffers++
+	}
+	s.lock.Unlock()
+
+	var res []*AllocHolder
+	for _, holder := range holders {
+		res = append(res, holder)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Bytes != res[j].Bytes {
+			return res[i].Bytes > res[j].Bytes
+		}
+		return res[i].Stack < res[j].Stack
+	})
+	if n >= 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// callerStack records the stack of the caller, skipping
+// the given number of frames above callerStack.
+func callerStack(skip int) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	return pcs[:runtime.Callers(skip+2, pcs)]
+}
+
+func formatStack(stack []uintptr) string {
+	if len(stack) == 0 {
+		return ""
+	}
+	var res bytes.Buffer
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&res, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return res.String()
+}
@@ -0,0 +1,90 @@
+package cudavec
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestTopHolders(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+	stats.Alloc(10)
+	stats.tracing = 1
+	allocSmall := func() {
+		stats.Alloc(100)
+	}
+	allocLarge := func() {
+		stats.Alloc(200)
+	}
+	for i := 0; i < 2; i++ {
+		allocSmall()
+	}
+	allocLarge()
+
+	holders := stats.TopHolders(-1, 0, nil)
+	if len(holders) != 3 {
+		t.Fatalf("expected 3 holders but got %d", len(holders))
+	}
+	if holders[0].Bytes != 200 || holders[0].Buffers != 2 ||
+		holders[1].Bytes != 200 || holders[1].Buffers != 1 {
+		t.Errorf("unexpected holders: %+v, %+v", holders[0], holders[1])
+	}
+	if !strings.Contains(holders[0].Stack, "TestTopHolders") {
+		t.Errorf("unexpected stack: %s", holders[0].Stack)
+	}
+	if holders[2].Stack != "" || holders[2].Bytes != 10 {
+		t.Errorf("unexpected untraced holder: %+v", holders[2])
+	}
+
+	holders = stats.TopHolders(1, 3, nil)
+	if len(holders) != 1 || holders[0].Bytes != 200 || holders[0].Buffers != 1 {
+		t.Errorf("unexpected holders after allocation 3: %+v", holders)
+	}
+
+	var ignore map[unsafe.Pointer]bool
+	for ptr, alloc := range stats.live {
+		if alloc.size == 200 {
+			ignore = map[unsafe.Pointer]bool{ptr: true}
+		}
+	}
+	if holders := stats.TopHolders(-1, 3, ignore); len(holders) != 0 {
+		t.Errorf("unexpected holders with ignored buffer: %+v", holders)
+	}
+}
+
+var leakedVectors []*vector32
+
+func TestLeakCheck(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	err := handle.LeakCheck(func() {
+		vec := c.MakeVectorData(randomSlice(100))
+		vec.Scale(float32(2))
+		vec.Data()
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = handle.LeakCheck(func() {
+		c.MakeMapper(3, []int{2, 1, 0})
+	})
+	if err != nil {
+		t.Errorf("cached mapper table reported as leak: %s", err)
+	}
+	if len(handle.mapperCache.Mappers()) == 0 {
+		t.Error("LeakCheck cleared the mapper cache")
+	}
+
+	err = handle.LeakCheck(func() {
+		vec := c.MakeVectorData(randomSlice(100)).(*vector32)
+		leakedVectors = append(leakedVectors, vec)
+	})
+	leakedVectors = nil
+	if err == nil {
+		t.Fatal("expected leak")
+	} else if !strings.Contains(err.Error(), "TestLeakCheck") {
+		t.Errorf("error does not contain caller: %s", err)
+	}
+}
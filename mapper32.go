@@ -1,10 +1,129 @@
 package cudavec
 
 import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/cuda"
 )
 
+// Mapper is implemented by every anyvec.Mapper created by
+// a Creator32.
+type Mapper interface {
+	anyvec.Mapper
+
+	// Table copies the mapper's table to the host.
+	// Entry i of the table is the input index which is
+	// stored at output index i by Map.
+	Table() []int
+
+	// Compose creates a Mapper which is equivalent to
+	// applying this mapper followed by other.
+	//
+	// The other mapper must come from a Creator32 with the
+	// same Handle, and its input size must match this
+	// mapper's output size.
+	// The new table is computed on the device.
+	Compose(other anyvec.Mapper) anyvec.Mapper
+
+	// Inverse creates a Mapper which undoes Map, so that
+	// mapping a vector with this mapper and then with the
+	// inverse yields the original vector.
+	//
+	// Only mappers whose table is a permutation of the
+	// input indices can be inverted; for other mappers, an
+	// error is returned.
+	// The table is checked and inverted on the host.
+	Inverse() (anyvec.Mapper, error)
+
+	// MarshalBinary encodes the mapper's input size and
+	// table.
+	MarshalBinary() ([]byte, error)
+
+	// UnmarshalBinary replaces the mapper with one decoded
+	// from the result of MarshalBinary.
+	UnmarshalBinary(data []byte) error
+}
+
+// UnmarshalMapper decodes a mapper that was encoded with
+// MarshalBinary.
+func (c *Creator32) UnmarshalMapper(data []byte) (anyvec.Mapper, error) {
+	res := &mapper32{creator: c}
+	if err := res.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ConcatMappers creates a Mapper which applies each mapper
+// to a different part of its input.
+//
+// The input and output of the resulting mapper are the
+// concatenations of the mappers' inputs and outputs,
+// respectively.
+//
+// Every mapper must come from a Creator32 with the same
+// Handle as c.
+func ConcatMappers(c *Creator32, mappers ...anyvec.Mapper) anyvec.Mapper {
+	var inSize, outSize int
+	for _, m := range mappers {
+		m32 := c.mapper32(m)
+		inSize += m32.inSize
+		outSize += m32.outSize
+		if int(int32(inSize)) != inSize || int(int32(outSize)) != outSize {
+			panic("mapper size is too big")
+		}
+	}
+	res := &mapper32{creator: c, inSize: inSize, outSize: outSize}
+	c.run(func() error {
+		buf, err := c.Handle.allocBuffer(SiteMapper, uintptr(outSize)*4)
+		if err != nil {
+			return err
+		}
+		res.table = buf
+		var inOffset, outOffset int
+		for _, m := range mappers {
+			m32 := m.(*mapper32)
+			if m32.outSize == 0 {
+				inOffset += m32.inSize
+				continue
+			}
+			subTable := cuda.Slice(buf, uintptr(outOffset)*4,
+				uintptr(outOffset+m32.outSize)*4)
+			if err := cuda.CopyBuffer(subTable, m32.table); err != nil {
+				return err
+			}
+			err := c.Handle.launchFlat("addScalerInt", m32.outSize,
+				inOffset, subTable, m32.outSize)
+			if err != nil {
+				return err
+			}
+			inOffset += m32.inSize
+			outOffset += m32.outSize
+		}
+		return nil
+	})
+	return res
+}
+
+// mapper32 converts a mapper to a *mapper32, panicking if
+// the mapper's table does not live on c's Handle.
+func (c *Creator32) mapper32(m anyvec.Mapper) *mapper32 {
+	m32, ok := m.(*mapper32)
+	if !ok {
+		panic(fmt.Sprintf("mapper of type %T was not created by a Creator32", m))
+	} else if m32.creator.Handle != c.Handle {
+		panic("mapper was created by a Creator32 with a different Handle")
+	}
+	return m32
+}
+
 type mapper32 struct {
 	creator *Creator32
 	table   cuda.Buffer
@@ -23,15 +142,161 @@ func newMapper32(c *Creator32, inSize int, table []int) *mapper32 {
 		}
 		ints32[i] = int32(x)
 	}
-	res := &mapper32{creator: c, inSize: inSize, outSize: len(table)}
+	return newMapper32Ints(c, inSize, ints32)
+}
+
+// newMapper32Ints creates a mapper from a validated table.
+func newMapper32Ints(c *Creator32, inSize int, ints32 []int32) *mapper32 {
+	res := &mapper32{creator: c, inSize: inSize, outSize: len(ints32)}
+	res.uploadTable(ints32)
+	return res
+}
+
+// uploadTable sets the mapper's table.
+//
+// If the Handle's mapper cache has a table with the same
+// input size and entries, its device buffer is reused.
+func (m *mapper32) uploadTable(ints32 []int32) {
+	c := m.creator
+	hash := sha256.Sum256(encodeMapperTable(m.inSize, ints32))
+
+	// Use a separate entry, since m may be changed by a
+	// later call to UnmarshalBinary.
+	entry := &mapper32{creator: c, inSize: m.inSize, outSize: m.outSize}
+	if cached, ok := c.Handle.mapperCache.GetOrAdd(hash, entry); ok {
+		// The cached mapper's table is set by a closure
+		// which has already been queued.
+		c.run(func() error {
+			m.table = cached.table
+			return nil
+		})
+		return
+	}
+
 	c.run(func() error {
-		buf, err := cuda.AllocBuffer(c.Handle.allocator, uintptr(len(table))*4)
+		buf, err := c.Handle.allocBuffer(SiteMapper, uintptr(len(ints32))*4)
 		if err != nil {
 			return err
 		}
-		res.table = buf
+		m.table = buf
+		entry.table = buf
 		return cuda.WriteBuffer(buf, ints32)
 	})
+}
+
+// DefaultMapperCacheBytes is the default limit on the size
+// of the tables in a Handle's mapper cache.
+const DefaultMapperCacheBytes = 64 << 20
+
+// SetMapperCacheLimit sets the maximum number of bytes of
+// tables kept in the mapper cache.
+//
+// MakeMapper and UnmarshalMapper reuse the device buffer
+// of a cached table with the same contents.
+// When the cache is full, the least recently used tables
+// are dropped from it, and they are freed once no mapper
+// uses them.
+// A limit of 0 disables the cache.
+func (h *Handle) SetMapperCacheLimit(limit uint64) {
+	h.mapperCache.SetLimit(limit)
+}
+
+// ClearMapperCache removes all of the tables cached by
+// MakeMapper, allowing them to be freed once the mappers
+// using them are no longer referenced.
+func (h *Handle) ClearMapperCache() {
+	h.mapperCache.Clear()
+}
+
+// mapperCache is an LRU cache of mapper tables, keyed by
+// the hash of their encoded contents.
+type mapperCache struct {
+	lock    sync.Mutex
+	limit   uint64
+	size    uint64
+	entries map[[sha256.Size]byte]*list.Element
+	lru     list.List
+}
+
+type mapperCacheEntry struct {
+	hash   [sha256.Size]byte
+	mapper *mapper32
+}
+
+// GetOrAdd looks up a table by its hash.
+//
+// If the table is cached, the cached mapper is returned
+// along with true.
+// Otherwise, the given mapper is added to the cache (if it
+// fits) and returned along with false.
+func (m *mapperCache) GetOrAdd(hash [sha256.Size]byte, mapper *mapper32) (*mapper32, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if elem, ok := m.entries[hash]; ok {
+		m.lru.MoveToFront(elem)
+		return elem.Value.(*mapperCacheEntry).mapper, true
+	}
+	size := mapperTableBytes(mapper)
+	if size > m.limit {
+		return mapper, false
+	}
+	if m.entries == nil {
+		m.entries = map[[sha256.Size]byte]*list.Element{}
+	}
+	m.entries[hash] = m.lru.PushFront(&mapperCacheEntry{hash: hash, mapper: mapper})
+	m.size += size
+	m.evict()
+	return mapper, false
+}
+
+// SetLimit changes the maximum size of the cache, evicting
+// tables if necessary.
+func (m *mapperCache) SetLimit(limit uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.limit = limit
+	m.evict()
+}
+
+// Clear removes every table from the cache.
+func (m *mapperCache) Clear() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries = nil
+	m.lru.Init()
+	m.size = 0
+}
+
+// Mappers returns the cached mappers, from most to least
+// recently used.
+func (m *mapperCache) Mappers() []*mapper32 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var res []*mapper32
+	for elem := m.lru.Front(); elem != nil; elem = elem.Next() {
+		res = append(res, elem.Value.(*mapperCacheEntry).mapper)
+	}
+	return res
+}
+
+func (m *mapperCache) evict() {
+	for m.size > m.limit {
+		entry := m.lru.Remove(m.lru.Back()).(*mapperCacheEntry)
+		delete(m.entries, entry.hash)
+		m.size -= mapperTableBytes(entry.mapper)
+	}
+}
+
+func mapperTableBytes(m *mapper32) uint64 {
+	return uint64(m.outSize) * 4
+}
+
+func encodeMapperTable(inSize int, ints32 []int32) []byte {
+	res := make([]byte, 8+4*len(ints32))
+	binary.LittleEndian.PutUint64(res, uint64(inSize))
+	for i, x := range ints32 {
+		binary.LittleEndian.PutUint32(res[8+4*i:], uint32(x))
+	}
 	return res
 }
 
@@ -52,12 +317,14 @@ func (m *mapper32) Map(in, out anyvec.Vector) {
 		panic("bad input size")
 	} else if out.Len() != m.outSize {
 		panic("bad out size")
-	} else if in == out {
-		panic("inputs overlap")
 	}
 	in32 := in.(*vector32)
 	out32 := out.(*vector32)
-	m.creator.run(func() error {
+	m.creator.Handle.sanitize([]operand{out32}, []operand{in32})
+	if in.Overlaps(out) {
+		panic("inputs overlap")
+	}
+	out32.run([]*vector32{out32}, func() error {
 		if in32.buffer == nil {
 			if out32.buffer != nil {
 				return cuda.ClearBuffer(out32.buffer)
@@ -67,9 +334,8 @@ func (m *mapper32) Map(in, out anyvec.Vector) {
 		if err := out32.lazyInit(false); err != nil {
 			return err
 		}
-		grid, block := out32.kernelSizes()
-		return m.creator.Handle.kernels32.Launch("mapForward", grid, 1, 1, block, 1, 1,
-			0, nil, out32.buffer, in32.buffer, m.table, m.outSize)
+		return m.creator.Handle.launchFlat("mapForward", out32.Len(),
+			out32.buffer, in32.buffer, m.table, m.outSize)
 	})
 }
 
@@ -78,17 +344,106 @@ func (m *mapper32) MapTranspose(in, out anyvec.Vector) {
 		panic("bad input size")
 	} else if out.Len() != m.inSize {
 		panic("bad out size")
-	} else if in == out {
-		panic("inputs overlap")
 	}
 	in32 := in.(*vector32)
 	out32 := out.(*vector32)
-	m.creator.run(func() error {
+	m.creator.Handle.sanitize([]operand{out32}, []operand{in32})
+	if in.Overlaps(out) {
+		panic("inputs overlap")
+	}
+	out32.run([]*vector32{out32}, func() error {
 		if err := lazyInitAll(true, in32, out32); err != nil {
 			return err
 		}
-		grid, block := in32.kernelSizes()
-		return m.creator.Handle.kernels32.Launch("mapBackward", grid, 1, 1, block, 1, 1,
-			0, nil, out32.buffer, in32.buffer, m.table, m.outSize)
+		return m.creator.Handle.launchFlat("mapBackward", in32.Len(),
+			out32.buffer, in32.buffer, m.table, m.outSize)
 	})
 }
+
+func (m *mapper32) Table() []int {
+	ints32 := make([]int32, m.outSize)
+	m.creator.runSync(func() error {
+		return cuda.ReadBuffer(ints32, m.table)
+	})
+	res := make([]int, len(ints32))
+	for i, x := range ints32 {
+		res[i] = int(x)
+	}
+	return res
+}
+
+func (m *mapper32) Compose(other anyvec.Mapper) anyvec.Mapper {
+	other32 := m.creator.mapper32(other)
+	if other32.inSize != m.outSize {
+		panic("mapper sizes do not match")
+	}
+	res := &mapper32{creator: m.creator, inSize: m.inSize, outSize: other32.outSize}
+	m.creator.run(func() error {
+		buf, err := m.creator.Handle.allocBuffer(SiteMapper,
+			uintptr(other32.outSize)*4)
+		if err != nil {
+			return err
+		}
+		res.table = buf
+		if other32.outSize == 0 {
+			return nil
+		}
+		return m.creator.Handle.launchFlat("composeTables", other32.outSize,
+			buf, m.table, other32.table, other32.outSize)
+	})
+	return res
+}
+
+func (m *mapper32) Inverse() (anyvec.Mapper, error) {
+	if m.inSize != m.outSize {
+		return nil, errors.New("invert mapper: table is not a permutation")
+	}
+	table := m.Table()
+	inverse := make([]int32, len(table))
+	for i := range inverse {
+		inverse[i] = -1
+	}
+	for i, x := range table {
+		if inverse[x] != -1 {
+			return nil, errors.New("invert mapper: table is not a permutation")
+		}
+		inverse[x] = int32(i)
+	}
+	return newMapper32Ints(m.creator, m.outSize, inverse), nil
+}
+
+func (m *mapper32) MarshalBinary() ([]byte, error) {
+	ints32 := make([]int32, m.outSize)
+	m.creator.runSync(func() error {
+		return cuda.ReadBuffer(ints32, m.table)
+	})
+	return encodeMapperTable(m.inSize, ints32), nil
+}
+
+func (m *mapper32) UnmarshalBinary(data []byte) error {
+	if m.creator == nil {
+		return errors.New("unmarshal mapper: no Creator32")
+	} else if len(data) < 8 || len(data)%4 != 0 {
+		return errors.New("unmarshal mapper: invalid data size")
+	}
+	// The size is unsigned, so negative sizes are also
+	// out of range.
+	inSize := binary.LittleEndian.Uint64(data)
+	if inSize > math.MaxInt32 {
+		return errors.New("unmarshal mapper: input size out of range")
+	}
+	ints32 := make([]int32, (len(data)-8)/4)
+	if int(int32(len(ints32))) != len(ints32) {
+		return errors.New("unmarshal mapper: mapper size is too big")
+	}
+	for i := range ints32 {
+		ints32[i] = int32(binary.LittleEndian.Uint32(data[8+4*i:]))
+		if ints32[i] < 0 || int64(ints32[i]) >= int64(inSize) {
+			return errors.New("unmarshal mapper: index out of range")
+		}
+	}
+	m.inSize = int(inSize)
+	m.outSize = len(ints32)
+	m.uploadTable(ints32)
+	return nil
+}
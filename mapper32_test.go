@@ -0,0 +1,218 @@
+package cudavec
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anyvec"
+)
+
+func TestMapperTable(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	table := []int{3, 0, 0, 2}
+	m := c.MakeMapper(4, table).(Mapper)
+	if actual := m.Table(); !reflect.DeepEqual(actual, table) {
+		t.Errorf("expected %v but got %v", table, actual)
+	}
+}
+
+func TestMapperCompose(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	first := c.MakeMapper(4, []int{3, 0, 0, 2})
+	second := c.MakeMapper(4, []int{1, 3, 3})
+	composed := first.(Mapper).Compose(second)
+	if composed.InSize() != 4 || composed.OutSize() != 3 {
+		t.Fatalf("bad sizes: %d, %d", composed.InSize(), composed.OutSize())
+	}
+
+	in := c.MakeVectorData([]float32{1, 2, 3, 4})
+	expected := c.MakeVector(3)
+	mid := c.MakeVector(4)
+	first.Map(in, mid)
+	second.Map(mid, expected)
+	actual := c.MakeVector(3)
+	composed.Map(in, actual)
+	assertClose(t, "compose", actual.Data().([]float32), expected.Data().([]float32))
+}
+
+func TestMapperInverse(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	inverse, err := c.MakeMapper(4, []int{2, 0, 3, 1}).(Mapper).Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{1, 3, 0, 2}
+	if actual := inverse.(Mapper).Table(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	for _, bad := range []anyvec.Mapper{
+		c.MakeMapper(4, []int{2, 0, 3}),
+		c.MakeMapper(3, []int{2, 0, 2}),
+	} {
+		if _, err := bad.(Mapper).Inverse(); err == nil {
+			t.Errorf("expected error for table %v", bad.(Mapper).Table())
+		}
+	}
+}
+
+func TestMapperHandleMismatch(t *testing.T) {
+	c1 := &Creator32{Handle: &Handle{}}
+	c2 := &Creator32{Handle: &Handle{}}
+	m1 := &mapper32{creator: c1, inSize: 2, outSize: 2}
+	m2 := &mapper32{creator: c2, inSize: 2, outSize: 2}
+	for i, f := range []func(){
+		func() { m1.Compose(m2) },
+		func() { ConcatMappers(c1, m1, m2) },
+		func() { ConcatMappers(c2, m1) },
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if msg, _ := r.(string); !strings.Contains(msg, "different Handle") {
+					t.Errorf("case %d: unexpected panic: %v", i, r)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestConcatMappers(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	m := ConcatMappers(c,
+		c.MakeMapper(2, []int{1, 1, 0}),
+		c.MakeMapper(3, []int{}),
+		c.MakeMapper(2, []int{0}))
+	if m.InSize() != 7 || m.OutSize() != 4 {
+		t.Fatalf("bad sizes: %d, %d", m.InSize(), m.OutSize())
+	}
+	expected := []int{1, 1, 0, 5}
+	if actual := m.(Mapper).Table(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestMapperMarshal(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	table := []int{4, 1, 1, 0, 3}
+	data, err := c.MakeMapper(5, table).(Mapper).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := c.UnmarshalMapper(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.InSize() != 5 {
+		t.Errorf("expected input size 5 but got %d", decoded.InSize())
+	}
+	if actual := decoded.(Mapper).Table(); !reflect.DeepEqual(actual, table) {
+		t.Errorf("expected %v but got %v", table, actual)
+	}
+
+	if _, err := c.UnmarshalMapper(data[:len(data)-1]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+	bad, _ := c.MakeMapper(6, []int{5}).(Mapper).MarshalBinary()
+	bad[0] = 5
+	if _, err := c.UnmarshalMapper(bad); err == nil {
+		t.Error("expected error for out-of-range index")
+	}
+	for _, size := range []uint64{1 << 31, 1<<64 - 1} {
+		empty := make([]byte, 8)
+		binary.LittleEndian.PutUint64(empty, size)
+		if _, err := c.UnmarshalMapper(empty); err == nil {
+			t.Errorf("expected error for input size %#x", size)
+		}
+	}
+}
+
+func TestMapMaxSize(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	(&vector32{size: 1 << 31}).MapMax(2)
+}
+
+func TestMapperCache(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	m1 := c.MakeMapper(3, []int{2, 0, 1}).(*mapper32)
+	m2 := c.MakeMapper(3, []int{2, 0, 1}).(*mapper32)
+	m3 := c.MakeMapper(4, []int{2, 0, 1}).(*mapper32)
+	c.runSync(func() error {
+		if m1.table != m2.table {
+			t.Error("identical tables should share a buffer")
+		}
+		if m1.table == m3.table {
+			t.Error("different input sizes should not share a buffer")
+		}
+		return nil
+	})
+
+	handle.ClearMapperCache()
+	m4 := c.MakeMapper(3, []int{2, 0, 1}).(*mapper32)
+	c.runSync(func() error {
+		if m1.table == m4.table {
+			t.Error("cache should have been cleared")
+		}
+		return nil
+	})
+}
+
+func TestMapperCacheLimit(t *testing.T) {
+	var cache mapperCache
+	cache.SetLimit(40)
+	mappers := make([]*mapper32, 4)
+	for i := range mappers {
+		mappers[i] = &mapper32{outSize: 4}
+	}
+	hash := func(i int) [sha256.Size]byte {
+		return sha256.Sum256([]byte{byte(i)})
+	}
+
+	for i := 0; i < 2; i++ {
+		if m, ok := cache.GetOrAdd(hash(i), mappers[i]); ok || m != mappers[i] {
+			t.Fatalf("mapper %d: unexpected cache hit", i)
+		}
+	}
+	if m, ok := cache.GetOrAdd(hash(0), mappers[3]); !ok || m != mappers[0] {
+		t.Fatal("expected cache hit for mapper 0")
+	}
+	cache.GetOrAdd(hash(2), mappers[2])
+	if actual := cache.Mappers(); !reflect.DeepEqual(actual, []*mapper32{mappers[2],
+		mappers[0]}) {
+		t.Errorf("least recently used mapper should be evicted: %v", actual)
+	}
+
+	big := &mapper32{outSize: 11}
+	if _, ok := cache.GetOrAdd(hash(3), big); ok || len(cache.Mappers()) != 2 {
+		t.Error("mapper larger than the limit should not be cached")
+	}
+
+	cache.SetLimit(16)
+	if actual := cache.Mappers(); !reflect.DeepEqual(actual, []*mapper32{mappers[2]}) {
+		t.Errorf("unexpected mappers after lowering limit: %v", actual)
+	}
+	cache.SetLimit(0)
+	if len(cache.Mappers()) != 0 {
+		t.Error("a limit of 0 should disable the cache")
+	}
+}
@@ -0,0 +1,356 @@
+package cudavec
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"unsafe"
+	"weak"
+
+	"github.com/unixpickle/cuda"
+)
+
+// Allocation sites reported in MemStats.
+const (
+	SiteLazyInit = "lazyInit"
+	SiteConcat   = "Concat"
+	SiteAddLogs  = "AddLogs"
+	SiteMapMax   = "MapMax"
+	SiteMapper   = "mapper"
+	SiteTemp     = "temp"
+	SiteOther    = "other"
+)
+
+// MemStats summarizes the device memory allocated through
+// a Handle.
+//
+// Only memory allocated through the Handle's allocator is
+// counted.
+type MemStats struct {
+	// AllocatedBytes is the number of bytes in live
+	// buffers.
+	//
+	// This includes buffers which are unreachable but have
+	// not been freed by their finalizers yet.
+	AllocatedBytes uint64
+
+	// PendingGCBytes is the part of AllocatedBytes held by
+	// buffers which the garbage collector has found to be
+	// unreachable, but which have not been freed yet.
+	//
+	// Buffers are only found to be unreachable by a
+	// garbage collection, so this does not include garbage
+	// created since the last collection.
+	PendingGCBytes uint64
+
+	// GapBytes is the number of free bytes between live
+	// buffers, and LargestGapBytes is the size of the
+	// largest such gap.
+	//
+	// These describe the allocator's address space, which
+	// is one contiguous region for the default BFC
+	// allocator, so memory after the last live buffer is
+	// not counted.
+	GapBytes        uint64
+	LargestGapBytes uint64
+
+	// Fragmentation is the fraction of GapBytes which is
+	// not part of the largest gap, or 0 if there are no
+	// gaps.
+	//
+	// A high value means that the free memory between
+	// buffers is split into pieces which may be too small
+	// for new allocations.
+	Fragmentation float64
+
+	// PeakBytes is the maximum value of AllocatedBytes
+	// since the Handle was created or ResetPeakMemory was
+	// called.
+	PeakBytes uint64
+
+	// LiveBuffers is the number of live buffers.
+	LiveBuffers int
+
+	// Allocs and Frees count every allocation and free.
+	Allocs uint64
+	Frees  uint64
+
+	// Sites breaks down allocations by call site.
+	Sites map[string]SiteMemStats
+}
+
+// SiteMemStats stores allocation counters for one call
+// site.
+//
+// Allocs and TotalBytes count every allocation made at
+// the site, while AllocatedBytes and LiveBuffers only
+// count buffers that have not been freed.
+type SiteMemStats struct {
+	Allocs         uint64
+	TotalBytes     uint64
+	AllocatedBytes uint64
+	LiveBuffers    int
+}
+
+// MemStats returns the current memory statistics.
+func (h *Handle) MemStats() MemStats {
+	return h.stats.Stats()
+}
+
+// ResetPeakMemory sets the peak in MemStats to the number
+// of bytes currently allocated.
+func (h *Handle) ResetPeakMemory() {
+	h.stats.ResetPeak()
+}
+
+// allocBuffer allocates a buffer and attributes it to the
+// given site.
+//
+// This should only be called on the context goroutine.
+func (h *Handle) allocBuffer(site string, size uintptr) (cuda.Buffer, error) {
+	h.stats.SetSite(site)
+	defer h.stats.SetSite(SiteOther)
+	buf, err := cuda.AllocBuffer(&bufferAllocator{statsAllocator: h.stats}, size)
+	if err != nil {
+		return nil, err
+	}
+	if owner := bufferOwner(buf); owner != nil {
+		buf.WithPtr(func(ptr unsafe.Pointer) {
+			h.stats.SetOwner(ptr, owner)
+		})
+	}
+	return buf, nil
+}
+
+// bufferOwner finds the object which frees a buffer's
+// memory when it is finalized.
+//
+// It returns nil if the buffer is not a pointer.
+func bufferOwner(buf cuda.Buffer) *byte {
+	val := reflect.ValueOf(buf)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return nil
+	}
+	return (*byte)(unsafe.Pointer(val.Pointer()))
+}
+
+// A bufferAllocator allocates a single buffer through a
+// statsAllocator and remembers which allocation it made.
+//
+// This way, a Free from the buffer's finalizer can be told
+// apart from the Free of a later allocation at the same
+// address.
+type bufferAllocator struct {
+	*statsAllocator
+	id uint64
+}
+
+func (b *bufferAllocator) Alloc(size uintptr) (unsafe.Pointer, error) {
+	ptr, id, err := b.statsAllocator.alloc(size)
+	b.id = id
+	return ptr, err
+}
+
+func (b *bufferAllocator) Free(ptr unsafe.Pointer, size uintptr) {
+	b.statsAllocator.free(ptr, size, b.id)
+}
+
+type liveAllocation struct {
+	id    uint64
+	site  string
+	size  uintptr
+	stack []uintptr
+
+	// owner weakly refers to the object that frees the
+	// allocation, if it is known.
+	owner weak.Pointer[byte]
+}
+
+// unreachable checks if the owner of the allocation has
+// been found to be unreachable by the garbage collector.
+func (l *liveAllocation) unreachable() bool {
+	return l.owner != (weak.Pointer[byte]{}) && l.owner.Value() == nil
+}
+
+// statsAllocator wraps an allocator to track its usage.
+type statsAllocator struct {
+	cuda.Allocator
+
+	lock  sync.Mutex
+	site  string
+	stats MemStats
+	live  map[unsafe.Pointer]liveAllocation
+	limit uint64
+
+	// reclaimed stores the IDs of the allocations which
+	// were freed by reclaim, and whose buffers have not yet
+	// called Free.
+	reclaimed map[uint64]bool
+
+	policy OOMPolicy
+
+	// trim, if non-nil, is called on the context goroutine
+	// before each retry to drop cached buffers.
+	trim func()
+
+	tracing int32
+	stack   []uintptr
+}
+
+func newStatsAllocator(a cuda.Allocator) *statsAllocator {
+	return &statsAllocator{
+		Allocator: a,
+		site:      SiteOther,
+		stats:     MemStats{Sites: map[string]SiteMemStats{}},
+		live:      map[unsafe.Pointer]liveAllocation{},
+		reclaimed: map[uint64]bool{},
+		policy:    DefaultOOMPolicy,
+	}
+}
+
+// SetSite sets the site for future allocations.
+func (s *statsAllocator) SetSite(site string) {
+	s.lock.Lock()
+	s.site = site
+	s.lock.Unlock()
+}
+
+// SetOwner records the object which frees an allocation
+// when it is finalized, so that the allocation can be
+// reported as pending GC once the object is unreachable.
+func (s *statsAllocator) SetOwner(ptr unsafe.Pointer, owner *byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if alloc, ok := s.live[ptr]; ok {
+		alloc.owner = weak.Make(owner)
+		s.live[ptr] = alloc
+	}
+}
+
+func (s *statsAllocator) Alloc(size uintptr) (unsafe.Pointer, error) {
+	ptr, _, err := s.alloc(size)
+	return ptr, err
+}
+
+// alloc allocates memory and returns the ID of the new
+// allocation.
+func (s *statsAllocator) alloc(size uintptr) (unsafe.Pointer, uint64, error) {
+	ptr, err := s.allocRetry(size)
+	if err != nil {
+		return nil, 0, err
+	}
+	var stack []uintptr
+	if s.Tracing() {
+		stack = callerStack(1)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stack != nil {
+		stack = s.stack
+	}
+	s.stats.Allocs++
+	s.live[ptr] = liveAllocation{
+		id:    s.stats.Allocs,
+		site:  s.site,
+		size:  size,
+		stack: stack,
+	}
+	s.stats.LiveBuffers++
+	s.stats.AllocatedBytes += uint64(size)
+	if s.stats.AllocatedBytes > s.stats.PeakBytes {
+		s.stats.PeakBytes = s.stats.AllocatedBytes
+	}
+	site := s.stats.Sites[s.site]
+	site.Allocs++
+	site.TotalBytes += uint64(size)
+	site.AllocatedBytes += uint64(size)
+	site.LiveBuffers++
+	s.stats.Sites[s.site] = site
+	return ptr, s.stats.Allocs, nil
+}
+
+func (s *statsAllocator) Free(ptr unsafe.Pointer, size uintptr) {
+	s.free(ptr, size, 0)
+}
+
+// free frees the allocation with the given ID, or the
+// allocation at ptr if the ID is 0.
+//
+// Allocations which were already freed by reclaim are
+// ignored.
+func (s *statsAllocator) free(ptr unsafe.Pointer, size uintptr, id uint64) {
+	s.lock.Lock()
+	if s.reclaimed[id] {
+		delete(s.reclaimed, id)
+		s.lock.Unlock()
+		return
+	}
+	if alloc, ok := s.live[ptr]; ok && (id == 0 || alloc.id == id) {
+		s.removeLive(ptr, alloc)
+	}
+	s.lock.Unlock()
+	s.Allocator.Free(ptr, size)
+}
+
+// removeLive updates the statistics for a freed buffer.
+//
+// The caller must hold s.lock.
+func (s *statsAllocator) removeLive(ptr unsafe.Pointer, alloc liveAllocation) {
+	delete(s.live, ptr)
+	s.stats.Frees++
+	s.stats.LiveBuffers--
+	s.stats.AllocatedBytes -= uint64(alloc.size)
+	site := s.stats.Sites[alloc.site]
+	site.AllocatedBytes -= uint64(alloc.size)
+	site.LiveBuffers--
+	s.stats.Sites[alloc.site] = site
+}
+
+// Stats returns a copy of the current statistics.
+func (s *statsAllocator) Stats() MemStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := s.stats
+	res.Sites = map[string]SiteMemStats{}
+	for name, site := range s.stats.Sites {
+		res.Sites[name] = site
+	}
+
+	type span struct {
+		start, end uintptr
+	}
+	spans := make([]span, 0, len(s.live))
+	for ptr, alloc := range s.live {
+		if alloc.unreachable() {
+			res.PendingGCBytes += uint64(alloc.size)
+		}
+		spans = append(spans, span{start: uintptr(ptr), end: uintptr(ptr) + alloc.size})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	var end uintptr
+	for i, sp := range spans {
+		if i > 0 && sp.start > end {
+			gap := uint64(sp.start - end)
+			res.GapBytes += gap
+			if gap > res.LargestGapBytes {
+				res.LargestGapBytes = gap
+			}
+		}
+		if sp.end > end {
+			end = sp.end
+		}
+	}
+	if res.GapBytes > 0 {
+		res.Fragmentation = 1 - float64(res.LargestGapBytes)/float64(res.GapBytes)
+	}
+	return res
+}
+
+// ResetPeak resets the peak to the current usage.
+func (s *statsAllocator) ResetPeak() {
+	s.lock.Lock()
+	s.stats.PeakBytes = s.stats.AllocatedBytes
+	s.lock.Unlock()
+}
@@ -0,0 +1,186 @@
+package cudavec
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/unixpickle/cuda"
+)
+
+type fakeAllocator struct {
+	allocated uintptr
+	limit     uintptr
+	live      map[unsafe.Pointer][]byte
+
+	// If reuse is set, freed memory is handed out again by
+	// later allocations of the same size.
+	reuse bool
+	freed [][]byte
+}
+
+func newFakeAllocator(limit uintptr) *fakeAllocator {
+	return &fakeAllocator{limit: limit, live: map[unsafe.Pointer][]byte{}}
+}
+
+func (f *fakeAllocator) Context() *cuda.Context {
+	return nil
+}
+
+func (f *fakeAllocator) Alloc(size uintptr) (unsafe.Pointer, error) {
+	if f.allocated+size > f.limit {
+		return nil, errors.New("out of memory")
+	}
+	f.allocated += size
+	for i, data := range f.freed {
+		if len(data) == int(size)+1 {
+			f.freed = append(f.freed[:i], f.freed[i+1:]...)
+			ptr := unsafe.Pointer(&data[0])
+			f.live[ptr] = data
+			return ptr, nil
+		}
+	}
+	data := make([]byte, size+1)
+	ptr := unsafe.Pointer(&data[0])
+	f.live[ptr] = data
+	return ptr, nil
+}
+
+func (f *fakeAllocator) Free(ptr unsafe.Pointer, size uintptr) {
+	if _, ok := f.live[ptr]; !ok {
+		panic("double free")
+	}
+	if f.reuse {
+		f.freed = append(f.freed, f.live[ptr])
+	}
+	delete(f.live, ptr)
+	f.allocated -= size
+}
+
+func TestMemStats(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+
+	stats.SetSite(SiteLazyInit)
+	p1, _ := stats.Alloc(100)
+	p2, _ := stats.Alloc(200)
+	stats.SetSite(SiteAddLogs)
+	p3, _ := stats.Alloc(300)
+	if _, err := stats.Alloc(1000); err == nil {
+		t.Fatal("expected allocation to fail")
+	}
+	stats.Free(p2, 200)
+
+	actual := stats.Stats()
+	if actual.AllocatedBytes != 400 || actual.PeakBytes != 600 {
+		t.Errorf("unexpected bytes: %d allocated, %d peak", actual.AllocatedBytes,
+			actual.PeakBytes)
+	}
+	if actual.LiveBuffers != 2 || actual.Allocs != 3 || actual.Frees != 1 {
+		t.Errorf("unexpected counts: %+v", actual)
+	}
+	expectedSites := map[string]SiteMemStats{
+		SiteLazyInit: {Allocs: 2, TotalBytes: 300, AllocatedBytes: 100, LiveBuffers: 1},
+		SiteAddLogs:  {Allocs: 1, TotalBytes: 300, AllocatedBytes: 300, LiveBuffers: 1},
+	}
+	if len(actual.Sites) != len(expectedSites) {
+		t.Errorf("unexpected sites: %v", actual.Sites)
+	}
+	for name, expected := range expectedSites {
+		if actual.Sites[name] != expected {
+			t.Errorf("site %s: expected %+v but got %+v", name, expected,
+				actual.Sites[name])
+		}
+	}
+
+	stats.Free(p1, 100)
+	stats.Free(p3, 300)
+	stats.ResetPeak()
+	actual = stats.Stats()
+	if actual.AllocatedBytes != 0 || actual.PeakBytes != 0 || actual.LiveBuffers != 0 {
+		t.Errorf("unexpected stats after free: %+v", actual)
+	}
+}
+
+// bumpAllocator places buffers one after another in a
+// single region, like a BFC allocator with no free chunks.
+type bumpAllocator struct {
+	fakeAllocator
+	region []byte
+	offset uintptr
+}
+
+func (b *bumpAllocator) Alloc(size uintptr) (unsafe.Pointer, error) {
+	ptr := unsafe.Pointer(&b.region[b.offset])
+	b.offset += size
+	return ptr, nil
+}
+
+func (b *bumpAllocator) Free(ptr unsafe.Pointer, size uintptr) {
+}
+
+func TestMemStatsFragmentation(t *testing.T) {
+	stats := newStatsAllocator(&bumpAllocator{region: make([]byte, 1000)})
+	var ptrs []unsafe.Pointer
+	sizes := []uintptr{100, 200, 100, 300, 10}
+	for _, size := range sizes {
+		ptr, _ := stats.Alloc(size)
+		ptrs = append(ptrs, ptr)
+	}
+	if actual := stats.Stats(); actual.GapBytes != 0 || actual.Fragmentation != 0 {
+		t.Errorf("unexpected fragmentation: %+v", actual)
+	}
+	stats.Free(ptrs[1], sizes[1])
+	stats.Free(ptrs[3], sizes[3])
+	actual := stats.Stats()
+	if actual.GapBytes != 500 || actual.LargestGapBytes != 300 ||
+		math.Abs(actual.Fragmentation-0.4) > 1e-8 {
+		t.Errorf("unexpected fragmentation: %+v", actual)
+	}
+
+	// Space before the first buffer and after the last one
+	// is not a gap.
+	stats.Free(ptrs[0], sizes[0])
+	stats.Free(ptrs[4], sizes[4])
+	if actual := stats.Stats(); actual.GapBytes != 0 {
+		t.Errorf("unexpected gaps: %+v", actual)
+	}
+}
+
+func TestMemStatsPendingGC(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+	kept := new([32]byte)
+	func() {
+		p1, _ := stats.Alloc(100)
+		p2, _ := stats.Alloc(200)
+		stats.SetOwner(p1, &kept[0])
+		stats.SetOwner(p2, &new([32]byte)[0])
+		stats.Alloc(300)
+	}()
+	runtime.GC()
+	if actual := stats.Stats().PendingGCBytes; actual != 200 {
+		t.Errorf("expected 200 pending bytes but got %d", actual)
+	}
+	runtime.KeepAlive(kept)
+}
+
+func TestHandleMemStats(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	before := handle.MemStats()
+	vec := c.MakeVector(1000)
+	vec.SetData(make([]float32, 1000))
+	c.Concat(vec, vec).Data()
+	after := handle.MemStats()
+
+	for _, site := range []string{SiteLazyInit, SiteConcat} {
+		if after.Sites[site].Allocs <= before.Sites[site].Allocs {
+			t.Errorf("no allocations recorded for %s", site)
+		}
+	}
+	if after.PeakBytes < before.AllocatedBytes+12000 {
+		t.Errorf("peak %d is too low", after.PeakBytes)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package cudavec
+
+import "io/ioutil"
+
+// mapFile reads a file into memory on platforms without
+// mmap support.
+func mapFile(path string) (data []byte, unmap func() error, err error) {
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,219 @@
+package cudavec
+
+import "github.com/unixpickle/anyvec"
+
+// NormStats stores the statistics computed by LayerNorm
+// or BatchNorm.
+// It is needed to compute gradients.
+type NormStats struct {
+	// Mean stores the mean of each normalized group.
+	Mean anyvec.Vector
+
+	// InvStd stores the reciprocal of the standard
+	// deviation of each normalized group.
+	InvStd anyvec.Vector
+}
+
+// LayerNorm normalizes each chunk of the input to have
+// zero mean and unit variance, then scales and shifts
+// every chunk.
+//
+// The scale and shift vectors have one entry per element
+// of a chunk.
+// The epsilon is added to every variance before it is
+// used.
+//
+// The result is written to out.
+func LayerNorm(in, out, scale, shift anyvec.Vector, chunkSize int,
+	epsilon float32) *NormStats {
+	in32, out32, scale32, shift32 := checkNormArgs(in, out, scale, shift, chunkSize)
+	rows := in.Len() / chunkSize
+	stats := newNormStats(in32.creator, rows)
+	if rows == 0 {
+		return stats
+	}
+	mean32 := stats.Mean.(*vector32)
+	invStd32 := stats.InvStd.(*vector32)
+	out32.run([]*vector32{out32, mean32, invStd32}, func() error {
+		err := lazyInitAll(true, in32, out32, scale32, shift32, mean32, invStd32)
+		if err != nil {
+			return err
+		}
+		threads := normThreads(chunkSize)
+		grid := capGrid(rows, in32.creator.Handle.maxGrid)
+		return in32.creator.Handle.launch("layerNorm", grid, 1, 1,
+			threads, 1, 1, threads*4, nil, out32.buffer, mean32.buffer, invStd32.buffer,
+			in32.buffer, scale32.buffer, shift32.buffer, rows, chunkSize, epsilon)
+	})
+	return stats
+}
+
+// LayerNormBackward propagates the gradient of LayerNorm.
+//
+// The gradients with respect to the input, scale, and
+// shift are added to inGrad, scaleGrad, and shiftGrad.
+func LayerNormBackward(in, scale anyvec.Vector, stats *NormStats,
+	outGrad, inGrad, scaleGrad, shiftGrad anyvec.Vector) {
+	checkNormGradArgs(in, scale, stats, outGrad, inGrad, scaleGrad, shiftGrad)
+	chunkSize := scale.Len()
+	rows := in.Len() / chunkSize
+	in32, outGrad32, scale32 := in.(*vector32), outGrad.(*vector32), scale.(*vector32)
+	inGrad32 := inGrad.(*vector32)
+	scaleGrad32, shiftGrad32 := scaleGrad.(*vector32), shiftGrad.(*vector32)
+	mean32, invStd32 := checkNormStats(stats, rows)
+	if rows == 0 {
+		return
+	}
+	inGrad32.run([]*vector32{inGrad32, scaleGrad32, shiftGrad32}, func() error {
+		err := lazyInitAll(true, in32, outGrad32, scale32, mean32, invStd32,
+			inGrad32, scaleGrad32, shiftGrad32)
+		if err != nil {
+			return err
+		}
+		threads := normThreads(chunkSize)
+		grid := capGrid(rows, in32.creator.Handle.maxGrid)
+		err = in32.creator.Handle.launch("layerNormBackward", grid,
+			1, 1, threads, 1, 1, threads*4, nil, inGrad32.buffer, outGrad32.buffer,
+			in32.buffer, scale32.buffer, mean32.buffer, invStd32.buffer, rows,
+			chunkSize)
+		if err != nil {
+			return err
+		}
+		return in32.creator.Handle.launchFlat("layerNormParamGrads", scale32.Len(),
+			scaleGrad32.buffer, shiftGrad32.buffer, outGrad32.buffer, in32.buffer,
+			mean32.buffer, invStd32.buffer, rows, chunkSize)
+	})
+}
+
+// BatchNorm normalizes each column of a row-major matrix
+// to have zero mean and unit variance, then scales and
+// shifts every row.
+//
+// The scale and shift vectors have one entry per column.
+// The epsilon is added to every variance before it is
+// used.
+//
+// The result is written to out.
+func BatchNorm(in, out, scale, shift anyvec.Vector, cols int,
+	epsilon float32) *NormStats {
+	in32, out32, scale32, shift32 := checkNormArgs(in, out, scale, shift, cols)
+	stats := newNormStats(in32.creator, cols)
+	if in.Len() == 0 {
+		return stats
+	}
+	mean32 := stats.Mean.(*vector32)
+	invStd32 := stats.InvStd.(*vector32)
+	out32.run([]*vector32{out32, mean32, invStd32}, func() error {
+		err := lazyInitAll(true, in32, out32, scale32, shift32, mean32, invStd32)
+		if err != nil {
+			return err
+		}
+		return in32.creator.Handle.launchFlat("batchNorm", scale32.Len(),
+			out32.buffer, mean32.buffer, invStd32.buffer, in32.buffer, scale32.buffer,
+			shift32.buffer, in.Len()/cols, cols, epsilon)
+	})
+	return stats
+}
+
+// BatchNormBackward propagates the gradient of BatchNorm.
+//
+// The gradients with respect to the input, scale, and
+// shift are added to inGrad, scaleGrad, and shiftGrad.
+func BatchNormBackward(in, scale anyvec.Vector, stats *NormStats,
+	outGrad, inGrad, scaleGrad, shiftGrad anyvec.Vector) {
+	checkNormGradArgs(in, scale, stats, outGrad, inGrad, scaleGrad, shiftGrad)
+	cols := scale.Len()
+	in32, outGrad32, scale32 := in.(*vector32), outGrad.(*vector32), scale.(*vector32)
+	inGrad32 := inGrad.(*vector32)
+	scaleGrad32, shiftGrad32 := scaleGrad.(*vector32), shiftGrad.(*vector32)
+	mean32, invStd32 := checkNormStats(stats, cols)
+	if in.Len() == 0 {
+		return
+	}
+	inGrad32.run([]*vector32{inGrad32, scaleGrad32, shiftGrad32}, func() error {
+		err := lazyInitAll(true, in32, outGrad32, scale32, mean32, invStd32,
+			inGrad32, scaleGrad32, shiftGrad32)
+		if err != nil {
+			return err
+		}
+		return in32.creator.Handle.launchFlat("batchNormBackward", scale32.Len(),
+			inGrad32.buffer, scaleGrad32.buffer, shiftGrad32.buffer, outGrad32.buffer,
+			in32.buffer, scale32.buffer, mean32.buffer, invStd32.buffer, in.Len()/cols,
+			cols)
+	})
+}
+
+func checkNormArgs(in, out, scale, shift anyvec.Vector, cols int) (in32, out32,
+	scale32, shift32 *vector32) {
+	if cols <= 0 {
+		panic("normalized size must be positive")
+	} else if in.Len()%cols != 0 {
+		panic("normalized size must divide vector size")
+	} else if in.Len() != out.Len() {
+		panic("length mismatch")
+	} else if scale.Len() != cols || shift.Len() != cols {
+		panic("bad scale or shift size")
+	}
+	in32, out32 = in.(*vector32), out.(*vector32)
+	scale32, shift32 = scale.(*vector32), shift.(*vector32)
+	out32.creator.Handle.sanitize([]operand{out32}, []operand{in32, scale32, shift32})
+	if in.Overlaps(out) || out.Overlaps(scale) || out.Overlaps(shift) {
+		panic("invalid overlap")
+	}
+	return
+}
+
+func checkNormGradArgs(in, scale anyvec.Vector, stats *NormStats, outGrad, inGrad,
+	scaleGrad, shiftGrad anyvec.Vector) {
+	cols := scale.Len()
+	if cols == 0 {
+		panic("normalized size must be positive")
+	} else if in.Len()%cols != 0 {
+		panic("normalized size must divide vector size")
+	} else if outGrad.Len() != in.Len() || inGrad.Len() != in.Len() {
+		panic("length mismatch")
+	} else if scaleGrad.Len() != cols || shiftGrad.Len() != cols {
+		panic("bad scale or shift gradient size")
+	}
+	inputs := []anyvec.Vector{in, scale, outGrad, stats.Mean, stats.InvStd}
+	outputs := []anyvec.Vector{inGrad, scaleGrad, shiftGrad}
+	var writes, reads []operand
+	for _, v := range inputs {
+		reads = append(reads, v.(*vector32))
+	}
+	for _, v := range outputs {
+		writes = append(writes, v.(*vector32))
+	}
+	in.(*vector32).creator.Handle.sanitize(writes, reads)
+	for i, out := range outputs {
+		for _, other := range append(inputs, outputs[:i]...) {
+			if out.Overlaps(other) {
+				panic("invalid overlap")
+			}
+		}
+	}
+}
+
+func checkNormStats(stats *NormStats, size int) (mean32, invStd32 *vector32) {
+	if stats.Mean.Len() != size || stats.InvStd.Len() != size {
+		panic("bad statistics size")
+	}
+	return stats.Mean.(*vector32), stats.InvStd.(*vector32)
+}
+
+func newNormStats(c *Creator32, size int) *NormStats {
+	return &NormStats{
+		Mean:   c.MakeVector(size),
+		InvStd: c.MakeVector(size),
+	}
+}
+
+// normThreads picks a power of 2 block size for kernels
+// that reduce across the columns of a row.
+func normThreads(cols int) uint {
+	threads := uint(256)
+	for int(threads/2) >= cols && threads > 32 {
+		threads /= 2
+	}
+	return threads
+}
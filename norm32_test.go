@@ -0,0 +1,129 @@
+package cudavec
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLayerNorm(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	// Use a chunk size larger than the block size to test
+	// the strided reductions.
+	for _, cols := range []int{7, 300} {
+		rows := 5
+		in := randomSlice(rows * cols)
+		scale := randomSlice(cols)
+		shift := randomSlice(cols)
+		outGrad := randomSlice(rows * cols)
+
+		expected := normReference(rows, cols, in, scale, shift, outGrad, false)
+
+		inVec := c.MakeVectorData(in)
+		scaleVec := c.MakeVectorData(scale)
+		out := c.MakeVector(len(in))
+		stats := LayerNorm(inVec, out, scaleVec, c.MakeVectorData(shift), cols, 1e-5)
+		assertClose(t, "output", out.Data().([]float32), expected.out)
+
+		inGrad := c.MakeVector(len(in))
+		scaleGrad := c.MakeVector(cols)
+		shiftGrad := c.MakeVector(cols)
+		LayerNormBackward(inVec, scaleVec, stats, c.MakeVectorData(outGrad), inGrad,
+			scaleGrad, shiftGrad)
+		assertClose(t, "input gradient", inGrad.Data().([]float32), expected.inGrad)
+		assertClose(t, "scale gradient", scaleGrad.Data().([]float32),
+			expected.scaleGrad)
+		assertClose(t, "shift gradient", shiftGrad.Data().([]float32),
+			expected.shiftGrad)
+	}
+}
+
+func TestBatchNorm(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	rows, cols := 9, 4
+	in := randomSlice(rows * cols)
+	scale := randomSlice(cols)
+	shift := randomSlice(cols)
+	outGrad := randomSlice(rows * cols)
+
+	expected := normReference(rows, cols, in, scale, shift, outGrad, true)
+
+	inVec := c.MakeVectorData(in)
+	scaleVec := c.MakeVectorData(scale)
+	out := c.MakeVector(len(in))
+	stats := BatchNorm(inVec, out, scaleVec, c.MakeVectorData(shift), cols, 1e-5)
+	assertClose(t, "output", out.Data().([]float32), expected.out)
+
+	inGrad := c.MakeVector(len(in))
+	scaleGrad := c.MakeVector(cols)
+	shiftGrad := c.MakeVector(cols)
+	BatchNormBackward(inVec, scaleVec, stats, c.MakeVectorData(outGrad), inGrad,
+		scaleGrad, shiftGrad)
+	assertClose(t, "input gradient", inGrad.Data().([]float32), expected.inGrad)
+	assertClose(t, "scale gradient", scaleGrad.Data().([]float32), expected.scaleGrad)
+	assertClose(t, "shift gradient", shiftGrad.Data().([]float32), expected.shiftGrad)
+}
+
+type normResults struct {
+	out       []float32
+	inGrad    []float32
+	scaleGrad []float32
+	shiftGrad []float32
+}
+
+// normReference computes layer normalization (or batch
+// normalization, if byColumn is set) and its gradients on
+// the host.
+func normReference(rows, cols int, in, scale, shift, outGrad []float32,
+	byColumn bool) *normResults {
+	res := &normResults{
+		out:       make([]float32, len(in)),
+		inGrad:    make([]float32, len(in)),
+		scaleGrad: make([]float32, cols),
+		shiftGrad: make([]float32, cols),
+	}
+	numGroups, groupSize := rows, cols
+	index := func(group, i int) int {
+		return group*cols + i
+	}
+	if byColumn {
+		numGroups, groupSize = cols, rows
+		index = func(group, i int) int {
+			return i*cols + group
+		}
+	}
+	for group := 0; group < numGroups; group++ {
+		var mean, variance float64
+		for i := 0; i < groupSize; i++ {
+			mean += float64(in[index(group, i)]) / float64(groupSize)
+		}
+		for i := 0; i < groupSize; i++ {
+			diff := float64(in[index(group, i)]) - mean
+			variance += diff * diff / float64(groupSize)
+		}
+		invStd := 1 / math.Sqrt(variance+1e-5)
+
+		normed := make([]float64, groupSize)
+		grads := make([]float64, groupSize)
+		var gradMean, gradDotMean float64
+		for i := 0; i < groupSize; i++ {
+			idx := index(group, i)
+			col := idx % cols
+			normed[i] = (float64(in[idx]) - mean) * invStd
+			res.out[idx] = float32(normed[i]*float64(scale[col]) + float64(shift[col]))
+			res.scaleGrad[col] += float32(normed[i] * float64(outGrad[idx]))
+			res.shiftGrad[col] += outGrad[idx]
+			grads[i] = float64(outGrad[idx] * scale[col])
+			gradMean += grads[i] / float64(groupSize)
+			gradDotMean += grads[i] * normed[i] / float64(groupSize)
+		}
+		for i := 0; i < groupSize; i++ {
+			res.inGrad[index(group, i)] = float32(invStd *
+				(grads[i] - gradMean - normed[i]*gradDotMean))
+		}
+	}
+	return res
+}
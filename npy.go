@@ -0,0 +1,307 @@
+package cudavec
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/anyvec"
+)
+
+var npyMagic = []byte("\x93NUMPY")
+
+// WriteNPY encodes a vector as a C-order float32 .npy
+// file.
+//
+// If shape is nil, the array is one-dimensional.
+// Otherwise, the product of the shape must equal the
+// vector's length, and no dimension may be negative.
+func WriteNPY(w io.Writer, v anyvec.Vector, shape []int) (err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("write npy: " + err.Error())
+		}
+	}()
+	if shape == nil {
+		shape = []int{v.Len()}
+	}
+	for _, x := range shape {
+		if x < 0 {
+			return errors.New("negative dimension in shape")
+		}
+	}
+	if shapeSize(shape) != v.Len() {
+		return errors.New("shape does not match vector length")
+	}
+
+	var shapeStrs []string
+	for _, x := range shape {
+		shapeStrs = append(shapeStrs, strconv.Itoa(x))
+	}
+	shapeStr := strings.Join(shapeStrs, ", ")
+	if len(shape) == 1 {
+		shapeStr += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }",
+		shapeStr)
+
+	// The header is padded so that the data is 64-byte
+	// aligned, and it ends with a newline.
+	prefixLen := len(npyMagic) + 2 + 2
+	padding := 64 - (prefixLen+len(header)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	header += strings.Repeat(" ", padding) + "\n"
+	if len(header) > math.MaxUint16 {
+		return errors.New("shape is too long")
+	}
+
+	var prefix bytes.Buffer
+	prefix.Write(npyMagic)
+	prefix.Write([]byte{1, 0})
+	binary.Write(&prefix, binary.LittleEndian, uint16(len(header)))
+	prefix.WriteString(header)
+	if _, err := w.Write(prefix.Bytes()); err != nil {
+		return err
+	}
+
+	return writeVectorData(w, v)
+}
+
+// ReadNPY decodes a .npy file onto the device.
+//
+// Supported dtypes are float16, float32, and float64 with
+// either byte order; values are converted to float32.
+// Fortran-order arrays are converted to C order.
+func ReadNPY(r io.Reader, c *Creator32) (vec anyvec.Vector, shape []int, err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("read npy: " + err.Error())
+		}
+	}()
+	header, err := readNPYHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	size := shapeSize(header.shape)
+	if size < 0 {
+		return nil, nil, errors.New("array is too large")
+	}
+
+	if header.fortranOrder && len(header.shape) > 1 {
+		data, err := readHostData(r, size, header.dtype)
+		if err != nil {
+			return nil, nil, err
+		}
+		floats := fortranToC(data, header.shape)
+		return c.MakeVectorData(floats), header.shape, nil
+	}
+
+	res, err := readVectorData(r, c, size, header.dtype)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, header.shape, nil
+}
+
+// WriteNPZ encodes named arrays as an .npz archive.
+//
+// The archive is not compressed, matching numpy.savez.
+func WriteNPZ(w io.Writer, arrays map[string]*Tensor) (err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("write npz: " + err.Error())
+		}
+	}()
+	var names []string
+	for name := range arrays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zipWriter := zip.NewWriter(w)
+	for _, name := range names {
+		entry, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:   name + ".npy",
+			Method: zip.Store,
+		})
+		if err != nil {
+			return err
+		}
+		array := arrays[name]
+		if err := WriteNPY(entry, array.Vector, array.Shape); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// ReadNPZ decodes an .npz archive onto the device.
+//
+// The resulting map is keyed by array name, without the
+// .npy extension.
+func ReadNPZ(r io.ReaderAt, size int64, c *Creator32) (res map[string]*Tensor,
+	err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("read npz: " + err.Error())
+		}
+	}()
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	res = map[string]*Tensor{}
+	for _, file := range zipReader.File {
+		if !strings.HasSuffix(file.Name, ".npy") {
+			continue
+		}
+		entry, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		vec, shape, err := ReadNPY(entry, c)
+		entry.Close()
+		if err != nil {
+			return nil, errors.New(file.Name + ": " + err.Error())
+		}
+		name := strings.TrimSuffix(file.Name, ".npy")
+		res[name] = &Tensor{Vector: vec, Shape: shape}
+	}
+	return res, nil
+}
+
+type npyHeader struct {
+	dtype        *floatFormat
+	fortranOrder bool
+	shape        []int
+}
+
+var (
+	npyDescrExpr   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortranExpr = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShapeExpr   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+func readNPYHeader(r io.Reader) (*npyHeader, error) {
+	prefix := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(prefix[:len(npyMagic)], npyMagic) {
+		return nil, errors.New("bad magic number")
+	}
+
+	var headerLen int
+	switch prefix[len(npyMagic)] {
+	case 1:
+		var size uint16
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		headerLen = int(size)
+	case 2, 3:
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		headerLen = int(size)
+	default:
+		return nil, fmt.Errorf("unsupported format version %d", prefix[len(npyMagic)])
+	}
+	headerData, err := ioutil.ReadAll(io.LimitReader(r, int64(headerLen)))
+	if err != nil {
+		return nil, err
+	} else if len(headerData) != headerLen {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return parseNPYHeader(string(headerData))
+}
+
+func parseNPYHeader(header string) (*npyHeader, error) {
+	descr := npyDescrExpr.FindStringSubmatch(header)
+	fortran := npyFortranExpr.FindStringSubmatch(header)
+	shape := npyShapeExpr.FindStringSubmatch(header)
+	if descr == nil || fortran == nil || shape == nil {
+		return nil, errors.New("malformed header")
+	}
+	dtype, err := parseNPYDescr(descr[1])
+	if err != nil {
+		return nil, err
+	}
+	res := &npyHeader{dtype: dtype, fortranOrder: fortran[1] == "True"}
+	for _, field := range strings.Split(shape[1], ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		dim, err := strconv.Atoi(strings.TrimSuffix(field, "L"))
+		if err != nil || dim < 0 {
+			return nil, errors.New("invalid shape: " + shape[1])
+		}
+		res.shape = append(res.shape, dim)
+	}
+	return res, nil
+}
+
+func parseNPYDescr(descr string) (*floatFormat, error) {
+	if len(descr) != 3 {
+		return nil, errors.New("unsupported dtype: " + descr)
+	}
+	var res floatFormat
+	switch descr[1:] {
+	case "f2":
+		res = *float16Format
+	case "f4":
+		res = *float32Format
+	case "f8":
+		res = *float64Format
+	default:
+		return nil, errors.New("unsupported dtype: " + descr)
+	}
+	switch descr[0] {
+	case '<', '|', '=':
+		res.byteOrder = binary.LittleEndian
+	case '>':
+		res.byteOrder = binary.BigEndian
+	default:
+		return nil, errors.New("unsupported byte order: " + descr)
+	}
+	return &res, nil
+}
+
+// fortranToC converts a column-major array to row-major.
+func fortranToC(data []float32, shape []int) []float32 {
+	res := make([]float32, len(data))
+	index := make([]int, len(shape))
+	for i := range res {
+		// Compute the column-major offset of the row-major
+		// index.
+		var offset int
+		stride := 1
+		for axis, x := range index {
+			offset += x * stride
+			stride *= shape[axis]
+		}
+		res[i] = data[offset]
+
+		for axis := len(index) - 1; axis >= 0; axis-- {
+			index[axis]++
+			if index[axis] < shape[axis] {
+				break
+			}
+			index[axis] = 0
+		}
+	}
+	return res
+}
@@ -0,0 +1,154 @@
+package cudavec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNPYRoundTrip(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	data := randomSlice(12)
+	var buf bytes.Buffer
+	if err := WriteNPY(&buf, c.MakeVectorData(data), []int{3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if (buf.Len()-len(data)*4)%64 != 0 {
+		t.Errorf("header is not aligned: %d bytes", buf.Len()-len(data)*4)
+	}
+	vec, shape, err := ReadNPY(&buf, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(shape, []int{3, 4}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	assertClose(t, "npy", vec.Data().([]float32), data)
+}
+
+func TestNPYConversion(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	// A 2x3 float64 big-endian Fortran-order array.
+	var buf bytes.Buffer
+	header := "{'descr': '>f8', 'fortran_order': True, 'shape': (2, 3), }\n"
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	binary.Write(&buf, binary.BigEndian, []float64{1, 4, 2, 5, 3, 6})
+
+	vec, shape, err := ReadNPY(&buf, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(shape, []int{2, 3}) {
+		t.Errorf("unexpected shape: %v", shape)
+	}
+	assertClose(t, "fortran", vec.Data().([]float32), []float32{1, 2, 3, 4, 5, 6})
+}
+
+func TestNPYBadInput(t *testing.T) {
+	// A Fortran-order header that claims far more data than
+	// the input holds.
+	var buf bytes.Buffer
+	header := "{'descr': '<f4', 'fortran_order': True, 'shape': (40000, 50000), }\n"
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	binary.Write(&buf, binary.LittleEndian, []float32{1, 2, 3})
+	if _, _, err := ReadNPY(&buf, nil); err == nil {
+		t.Error("expected error for truncated Fortran-order data")
+	}
+
+	vec := &vector32{size: 3}
+	for _, shape := range [][]int{{-1, -3}, {3, -1, -1}} {
+		if err := WriteNPY(&bytes.Buffer{}, vec, shape); err == nil {
+			t.Errorf("expected error for shape %v", shape)
+		}
+	}
+}
+
+func TestNPZRoundTrip(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	arrays := map[string]*Tensor{
+		"embeddings": {Vector: c.MakeVectorData(randomSlice(6)), Shape: []int{2, 3}},
+		"bias":       {Vector: c.MakeVectorData(randomSlice(3))},
+	}
+	var buf bytes.Buffer
+	if err := WriteNPZ(&buf, arrays); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadNPZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("expected 2 arrays but got %d", len(decoded))
+	}
+	if !reflect.DeepEqual(decoded["bias"].Shape, []int{3}) {
+		t.Errorf("unexpected shape: %v", decoded["bias"].Shape)
+	}
+	for name, array := range arrays {
+		assertClose(t, name, decoded[name].Vector.Data().([]float32),
+			array.Vector.Data().([]float32))
+	}
+}
+
+func TestParseNPYHeader(t *testing.T) {
+	header, err := parseNPYHeader("{'descr': '<f2', 'fortran_order': False, " +
+		"'shape': (5,), }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.dtype.size != 2 || header.fortranOrder ||
+		!reflect.DeepEqual(header.shape, []int{5}) {
+		t.Errorf("unexpected header: %+v", header)
+	}
+
+	header, err = parseNPYHeader("{'descr': '<f4', 'fortran_order': False, 'shape': ()}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header.shape) != 0 || shapeSize(header.shape) != 1 {
+		t.Errorf("unexpected scalar shape: %v", header.shape)
+	}
+
+	for _, bad := range []string{
+		"{'descr': '<i4', 'fortran_order': False, 'shape': (5,), }",
+		"{'descr': '<f4', 'shape': (5,), }",
+		"{'descr': '<f4', 'fortran_order': False, 'shape': (-1,), }",
+	} {
+		if _, err := parseNPYHeader(bad); err == nil {
+			t.Errorf("expected error for header: %s", bad)
+		}
+	}
+}
+
+func TestFloat16To32(t *testing.T) {
+	cases := map[uint16]float32{
+		0x0000: 0,
+		0x3c00: 1,
+		0xc000: -2,
+		0x3555: 0.333251953125,
+		0x7bff: 65504,
+		0x0001: 5.960464477539063e-08,
+		0x7c00: float32(math.Inf(1)),
+	}
+	for h, expected := range cases {
+		if actual := float16To32(h); actual != expected {
+			t.Errorf("0x%04x: expected %v but got %v", h, expected, actual)
+		}
+	}
+	if !math.IsNaN(float64(float16To32(0x7e00))) {
+		t.Error("expected NaN")
+	}
+}
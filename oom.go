@@ -0,0 +1,190 @@
+package cudavec
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+	"unsafe"
+)
+
+// finalizerTimeout limits how long an allocation waits
+// for pending finalizers before retrying.
+const finalizerTimeout = time.Second
+
+var errMemoryLimit = errors.New("memory limit exceeded")
+
+// An OOMPolicy determines what a Handle does when an
+// allocation fails.
+//
+// Before each retry, the cached scratch buffers of the
+// Handle are dropped, the garbage collector is run, and
+// pending finalizers are waited for, so that finalizers in
+// the application may release their vectors.
+// Then the buffers which the garbage collector finds to be
+// unreachable are freed right away.
+// Their own finalizers cannot free them in time, since
+// they queue the frees on the context goroutine, which is
+// busy with the failed allocation.
+// Buffers released by an operation that is still running
+// cannot be reclaimed this way.
+//
+// Vectors are never moved to host memory, since slices
+// of a vector refer directly to its device buffer.
+// OnRetry may be used to release memory held by the
+// application instead.
+type OOMPolicy struct {
+	// Retries is the number of times a failed allocation
+	// is retried.
+	Retries int
+
+	// OnRetry, if non-nil, is called before each retry
+	// with the attempt number, starting at 1.
+	//
+	// It is called on the context goroutine, so it must
+	// not wait for any vector operations.
+	OnRetry func(attempt int, size uintptr, stats MemStats)
+}
+
+// DefaultOOMPolicy is the policy used by new Handles.
+var DefaultOOMPolicy = OOMPolicy{Retries: 2}
+
+// An OOMError is returned when an allocation fails after
+// all of the retries from the OOMPolicy.
+type OOMError struct {
+	// Size is the requested number of bytes.
+	Size uintptr
+
+	// Stats is the state of memory after the last retry.
+	Stats MemStats
+
+	// Limit is the memory limit, or 0 if there is none.
+	Limit uint64
+
+	// Err is the error from the last attempt.
+	Err error
+}
+
+// Error returns a description of the failure.
+func (o *OOMError) Error() string {
+	limit := "none"
+	if o.Limit != 0 {
+		limit = fmt.Sprint(o.Limit)
+	}
+	return fmt.Sprintf("out of device memory: requested %d bytes with %d allocated "+
+		"(limit %s): %s", o.Size, o.Stats.AllocatedBytes, limit, o.Err)
+}
+
+// SetMemoryLimit sets the maximum number of bytes that
+// the Handle may allocate.
+//
+// A limit of 0 means no limit.
+// Buffers which were allocated before the limit was set
+// count towards it.
+func (h *Handle) SetMemoryLimit(limit uint64) {
+	h.stats.lock.Lock()
+	h.stats.limit = limit
+	h.stats.lock.Unlock()
+}
+
+// MemoryLimit returns the memory limit, or 0 if there is
+// no limit.
+func (h *Handle) MemoryLimit() uint64 {
+	h.stats.lock.Lock()
+	defer h.stats.lock.Unlock()
+	return h.stats.limit
+}
+
+// SetOOMPolicy sets the policy for failed allocations.
+func (h *Handle) SetOOMPolicy(p OOMPolicy) {
+	h.stats.lock.Lock()
+	h.stats.policy = p
+	h.stats.lock.Unlock()
+}
+
+func (s *statsAllocator) allocRetry(size uintptr) (unsafe.Pointer, error) {
+	ptr, err := s.allocLimit(size)
+	s.lock.Lock()
+	policy := s.policy
+	s.lock.Unlock()
+	for attempt := 1; err != nil && attempt <= policy.Retries; attempt++ {
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, size, s.Stats())
+		}
+		if s.trim != nil {
+			s.trim()
+		}
+		drainFinalizers()
+		// Buffers which were only reachable from the objects
+		// that were just finalized are found by another
+		// collection.
+		runtime.GC()
+		s.reclaim()
+		ptr, err = s.allocLimit(size)
+	}
+	if err != nil {
+		s.lock.Lock()
+		limit := s.limit
+		s.lock.Unlock()
+		return nil, &OOMError{Size: size, Stats: s.Stats(), Limit: limit, Err: err}
+	}
+	return ptr, nil
+}
+
+func (s *statsAllocator) allocLimit(size uintptr) (unsafe.Pointer, error) {
+	s.lock.Lock()
+	overLimit := s.limit != 0 && s.stats.AllocatedBytes+uint64(size) > s.limit
+	s.lock.Unlock()
+	if overLimit {
+		return nil, errMemoryLimit
+	}
+	return s.Allocator.Alloc(size)
+}
+
+// reclaim frees every allocation whose owner has been
+// found to be unreachable, without waiting for the owner's
+// finalizer.
+//
+// The later call to Free from the finalizer is ignored,
+// even if the address has been reused by then.
+func (s *statsAllocator) reclaim() {
+	type allocation struct {
+		ptr  unsafe.Pointer
+		size uintptr
+	}
+	var freed []allocation
+	s.lock.Lock()
+	for ptr, alloc := range s.live {
+		if alloc.unreachable() {
+			s.removeLive(ptr, alloc)
+			s.reclaimed[alloc.id] = true
+			freed = append(freed, allocation{ptr: ptr, size: alloc.size})
+		}
+	}
+	s.lock.Unlock()
+	for _, alloc := range freed {
+		s.Allocator.Free(alloc.ptr, alloc.size)
+	}
+}
+
+// drainFinalizers runs the garbage collector and waits for
+// the finalizers it queues to run.
+func drainFinalizers() {
+	// Finalizers run one at a time on a single goroutine,
+	// so once the sentinel is finalized, the rest of the
+	// finalizers from the same collection have usually
+	// run as well.
+	// The sentinel is large enough to avoid the tiny
+	// allocator, whose objects may never be finalized.
+	done := make(chan struct{})
+	sentinel := new([32]byte)
+	runtime.SetFinalizer(sentinel, func(*[32]byte) {
+		close(done)
+	})
+	sentinel = nil
+	runtime.GC()
+	select {
+	case <-done:
+	case <-time.After(finalizerTimeout):
+	}
+}
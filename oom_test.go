@@ -0,0 +1,157 @@
+package cudavec
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+	stats.limit = 500
+	stats.policy = OOMPolicy{}
+	if _, err := stats.Alloc(400); err != nil {
+		t.Fatal(err)
+	}
+	_, err := stats.Alloc(200)
+	if oom, ok := err.(*OOMError); !ok {
+		t.Fatalf("expected OOMError but got %v", err)
+	} else if oom.Size != 200 || oom.Limit != 500 || oom.Err != errMemoryLimit {
+		t.Errorf("unexpected error: %+v", oom)
+	}
+
+	stats.limit = 0
+	if _, err := stats.Alloc(200); err != nil {
+		t.Error(err)
+	}
+	if _, err := stats.Alloc(500); err == nil {
+		t.Error("expected allocator failure")
+	}
+}
+
+func TestOOMPolicyRetry(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+	ptr, _ := stats.Alloc(800)
+	var attempts []int
+	stats.policy = OOMPolicy{
+		Retries: 3,
+		OnRetry: func(attempt int, size uintptr, s MemStats) {
+			attempts = append(attempts, attempt)
+			if size != 300 || s.AllocatedBytes != 800 {
+				t.Errorf("unexpected retry arguments: %d, %+v", size, s)
+			}
+			if attempt == 2 {
+				stats.Free(ptr, 800)
+			}
+		},
+	}
+	if _, err := stats.Alloc(300); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 {
+		t.Errorf("unexpected attempts: %v", attempts)
+	}
+}
+
+func TestOOMPolicyFinalizers(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+	stats.policy = OOMPolicy{Retries: 1}
+
+	// Simulate a buffer whose finalizer queues its free on
+	// the context, which is busy with the allocation.
+	queue := make(chan func(), 1)
+	func() {
+		buf := &bufferAllocator{statsAllocator: stats}
+		ptr, _ := buf.Alloc(800)
+		owner := new([32]byte)
+		stats.SetOwner(ptr, &owner[0])
+		runtime.SetFinalizer(owner, func(*[32]byte) {
+			queue <- func() {
+				buf.Free(ptr, 800)
+			}
+		})
+	}()
+	ptr, err := stats.Alloc(300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := stats.Stats(); actual.Frees != 1 || actual.AllocatedBytes != 300 {
+		t.Errorf("buffer was not reclaimed: %+v", actual)
+	}
+
+	// The queued free must not free the buffer again.
+	(<-queue)()
+	if actual := stats.Stats(); actual.Frees != 1 || actual.LiveBuffers != 1 {
+		t.Errorf("unexpected stats after queued free: %+v", actual)
+	}
+	stats.Free(ptr, 300)
+	if actual := stats.Stats(); actual.AllocatedBytes != 0 || actual.LiveBuffers != 0 {
+		t.Errorf("unexpected stats after free: %+v", actual)
+	}
+}
+
+func TestOOMPolicyAppFinalizers(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+	stats.policy = OOMPolicy{Retries: 1}
+
+	// Simulate an application object with a finalizer
+	// which refers to a buffer, keeping the buffer alive
+	// until the finalizer has run.
+	type appObject struct {
+		buffer *[32]byte
+		pad    [32]byte
+	}
+	func() {
+		buf := &bufferAllocator{statsAllocator: stats}
+		ptr, _ := buf.Alloc(800)
+		owner := new([32]byte)
+		stats.SetOwner(ptr, &owner[0])
+		obj := &appObject{buffer: owner}
+		runtime.SetFinalizer(obj, func(*appObject) {})
+	}()
+	if _, err := stats.Alloc(300); err != nil {
+		t.Fatal(err)
+	}
+	if actual := stats.Stats(); actual.Frees != 1 || actual.AllocatedBytes != 300 {
+		t.Errorf("buffer was not reclaimed: %+v", actual)
+	}
+}
+
+func TestOOMPolicyAddressReuse(t *testing.T) {
+	allocator := newFakeAllocator(1000)
+	allocator.reuse = true
+	stats := newStatsAllocator(allocator)
+	stats.policy = OOMPolicy{Retries: 1}
+
+	queue := make(chan func(), 1)
+	var oldPtr unsafe.Pointer
+	func() {
+		buf := &bufferAllocator{statsAllocator: stats}
+		oldPtr, _ = buf.Alloc(800)
+		owner := new([32]byte)
+		stats.SetOwner(oldPtr, &owner[0])
+		runtime.SetFinalizer(owner, func(*[32]byte) {
+			queue <- func() {
+				buf.Free(oldPtr, 800)
+			}
+		})
+	}()
+	buf := &bufferAllocator{statsAllocator: stats}
+	ptr, err := buf.Alloc(800)
+	if err != nil {
+		t.Fatal(err)
+	} else if ptr != oldPtr {
+		t.Fatal("address was not reused")
+	}
+
+	// The queued free refers to the reclaimed buffer, not
+	// to the new buffer at the same address.
+	(<-queue)()
+	if actual := stats.Stats(); actual.Frees != 1 || actual.LiveBuffers != 1 {
+		t.Errorf("unexpected stats after queued free: %+v", actual)
+	}
+	buf.Free(ptr, 800)
+	if actual := stats.Stats(); actual.AllocatedBytes != 0 || actual.LiveBuffers != 0 {
+		t.Errorf("unexpected stats after free: %+v", actual)
+	}
+}
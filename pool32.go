@@ -0,0 +1,163 @@
+package cudavec
+
+import (
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/cuda"
+)
+
+// Pool2D describes a 2D pooling operation over batches of
+// NHWC images stored in vectors from a Creator32.
+//
+// The zero values of StrideX and StrideY are treated as
+// the window width and height, respectively.
+// Padding must be smaller than the window, and padded
+// entries never contribute to a window.
+type Pool2D struct {
+	InputWidth  int
+	InputHeight int
+	InputDepth  int
+
+	WindowWidth  int
+	WindowHeight int
+
+	StrideX int
+	StrideY int
+
+	PaddingX int
+	PaddingY int
+}
+
+// OutputWidth returns the width of the output images.
+func (p *Pool2D) OutputWidth() int {
+	return (p.InputWidth+2*p.PaddingX-p.WindowWidth)/p.strideX() + 1
+}
+
+// OutputHeight returns the height of the output images.
+func (p *Pool2D) OutputHeight() int {
+	return (p.InputHeight+2*p.PaddingY-p.WindowHeight)/p.strideY() + 1
+}
+
+// MaxPool writes the maximum of each window to out.
+//
+// The returned Mapper maps the input to the output.
+// Its MapTranspose method can be used to propagate
+// gradients back through the pooling operation.
+func (p *Pool2D) MaxPool(batch int, in, out anyvec.Vector) anyvec.Mapper {
+	p.checkShapes(batch, in, out)
+	in32 := in.(*vector32)
+	out32 := out.(*vector32)
+	p.checkAccess(out32, in32)
+	res := &mapper32{creator: in32.creator, inSize: in.Len(), outSize: out.Len()}
+	out32.run([]*vector32{out32}, func() error {
+		if err := lazyInitAll(true, in32, out32); err != nil {
+			return err
+		}
+		buf, err := in32.creator.Handle.allocBuffer(SiteMapper,
+			uintptr(out32.Len())*4)
+		if err != nil {
+			return err
+		}
+		res.table = buf
+		return p.launch(in32.creator.Handle, "maxPool", out32.Len(), out32.buffer,
+			buf, in32.buffer)
+	})
+	return res
+}
+
+// AvgPool writes the mean of each window to out.
+func (p *Pool2D) AvgPool(batch int, in, out anyvec.Vector) {
+	p.checkShapes(batch, in, out)
+	in32 := in.(*vector32)
+	out32 := out.(*vector32)
+	p.checkAccess(out32, in32)
+	out32.run([]*vector32{out32}, func() error {
+		if err := lazyInitAll(true, in32, out32); err != nil {
+			return err
+		}
+		return p.launch(in32.creator.Handle, "avgPool", out32.Len(), out32.buffer,
+			in32.buffer)
+	})
+}
+
+// AvgPoolBackward propagates the gradient of AvgPool and
+// adds the result to inGrad.
+func (p *Pool2D) AvgPoolBackward(batch int, outGrad, inGrad anyvec.Vector) {
+	p.checkShapes(batch, inGrad, outGrad)
+	outGrad32 := outGrad.(*vector32)
+	inGrad32 := inGrad.(*vector32)
+	p.checkAccess(inGrad32, outGrad32)
+	inGrad32.run([]*vector32{inGrad32}, func() error {
+		if err := lazyInitAll(true, outGrad32, inGrad32); err != nil {
+			return err
+		}
+		return p.launch(inGrad32.creator.Handle, "avgPoolBackward", outGrad32.Len(),
+			inGrad32.buffer, outGrad32.buffer)
+	})
+}
+
+func (p *Pool2D) checkShapes(batch int, in, out anyvec.Vector) {
+	if batch < 0 {
+		panic("batch size cannot be negative")
+	} else if p.InputWidth < 0 || p.InputHeight < 0 || p.InputDepth < 0 {
+		panic("input dimensions cannot be negative")
+	} else if p.WindowWidth <= 0 || p.WindowHeight <= 0 {
+		panic("window dimensions must be positive")
+	} else if p.StrideX < 0 || p.StrideY < 0 {
+		panic("stride cannot be negative")
+	} else if p.PaddingX < 0 || p.PaddingY < 0 {
+		panic("padding cannot be negative")
+	} else if p.PaddingX >= p.WindowWidth || p.PaddingY >= p.WindowHeight {
+		panic("padding must be smaller than window")
+	} else if p.InputWidth+2*p.PaddingX < p.WindowWidth ||
+		p.InputHeight+2*p.PaddingY < p.WindowHeight {
+		panic("window does not fit in padded input")
+	} else if p.InputWidth == 0 || p.InputHeight == 0 {
+		// Since padding is smaller than the window, every
+		// window covers an input pixel unless the input is
+		// empty.
+		panic("window has no input pixels")
+	}
+	inSize := batch * p.InputWidth * p.InputHeight * p.InputDepth
+	outSize := batch * p.OutputWidth() * p.OutputHeight() * p.InputDepth
+	if int(int32(inSize)) != inSize || int(int32(outSize)) != outSize {
+		panic("pooling size is too big")
+	} else if in.Len() != inSize {
+		panic("bad input size")
+	} else if out.Len() != outSize {
+		panic("bad output size")
+	}
+}
+
+// checkAccess checks that the vector written by a pooling
+// operation does not overlap the one it reads.
+func (p *Pool2D) checkAccess(written, read *vector32) {
+	written.creator.Handle.sanitize([]operand{written}, []operand{read})
+	if written.Overlaps(read) {
+		panic("invalid overlap")
+	}
+}
+
+func (p *Pool2D) launch(h *Handle, kernel string, n int, buffers ...cuda.Buffer) error {
+	var args []interface{}
+	for _, b := range buffers {
+		args = append(args, b)
+	}
+	args = append(args, n, p.InputWidth, p.InputHeight, p.InputDepth,
+		p.OutputWidth(), p.OutputHeight(), p.WindowWidth, p.WindowHeight,
+		p.strideX(), p.strideY(), p.PaddingX, p.PaddingY)
+	return h.launchFlat(kernel, n, args...)
+}
+
+func (p *Pool2D) strideX() int {
+	if p.StrideX == 0 {
+		return p.WindowWidth
+	}
+	return p.StrideX
+}
+
+func (p *Pool2D) strideY() int {
+	if p.StrideY == 0 {
+		return p.WindowHeight
+	}
+	return p.StrideY
+}
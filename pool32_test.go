@@ -0,0 +1,128 @@
+package cudavec
+
+import "testing"
+
+func TestPool2D(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	pool := &Pool2D{
+		InputWidth:   7,
+		InputHeight:  5,
+		InputDepth:   3,
+		WindowWidth:  3,
+		WindowHeight: 2,
+		StrideX:      2,
+		PaddingX:     1,
+		PaddingY:     1,
+	}
+	batch := 2
+	outSize := batch * pool.OutputWidth() * pool.OutputHeight() * pool.InputDepth
+
+	in := randomSlice(batch * pool.InputWidth * pool.InputHeight * pool.InputDepth)
+	outGrad := randomSlice(outSize)
+	expectedMax, expectedMaxGrad, expectedAvg, expectedAvgGrad := poolReference(pool,
+		batch, in, outGrad)
+
+	inVec := c.MakeVectorData(in)
+	actualMax := c.MakeVector(outSize)
+	mapper := pool.MaxPool(batch, inVec, actualMax)
+	assertClose(t, "max", actualMax.Data().([]float32), expectedMax)
+
+	actualMaxGrad := c.MakeVector(len(in))
+	mapper.MapTranspose(c.MakeVectorData(outGrad), actualMaxGrad)
+	assertClose(t, "max gradient", actualMaxGrad.Data().([]float32), expectedMaxGrad)
+
+	actualAvg := c.MakeVector(outSize)
+	pool.AvgPool(batch, inVec, actualAvg)
+	assertClose(t, "average", actualAvg.Data().([]float32), expectedAvg)
+
+	actualAvgGrad := c.MakeVector(len(in))
+	pool.AvgPoolBackward(batch, c.MakeVectorData(outGrad), actualAvgGrad)
+	assertClose(t, "average gradient", actualAvgGrad.Data().([]float32), expectedAvgGrad)
+}
+
+// poolReference computes max and average pooling and
+// their gradients on the host.
+func poolReference(p *Pool2D, batch int, in, outGrad []float32) (maxOut, maxGrad,
+	avgOut, avgGrad []float32) {
+	outW, outH := p.OutputWidth(), p.OutputHeight()
+	maxOut = make([]float32, len(outGrad))
+	avgOut = make([]float32, len(outGrad))
+	maxGrad = make([]float32, len(in))
+	avgGrad = make([]float32, len(in))
+	for b := 0; b < batch; b++ {
+		for oy := 0; oy < outH; oy++ {
+			for ox := 0; ox < outW; ox++ {
+				for z := 0; z < p.InputDepth; z++ {
+					outIdx := ((b*outH+oy)*outW+ox)*p.InputDepth + z
+					var indices []int
+					for wy := 0; wy < p.WindowHeight; wy++ {
+						for wx := 0; wx < p.WindowWidth; wx++ {
+							x := ox*p.strideX() + wx - p.PaddingX
+							y := oy*p.strideY() + wy - p.PaddingY
+							if x < 0 || y < 0 || x >= p.InputWidth || y >= p.InputHeight {
+								continue
+							}
+							indices = append(indices,
+								((b*p.InputHeight+y)*p.InputWidth+x)*p.InputDepth+z)
+						}
+					}
+					maxIdx := indices[0]
+					for _, idx := range indices {
+						if in[idx] > in[maxIdx] {
+							maxIdx = idx
+						}
+						avgOut[outIdx] += in[idx] / float32(len(indices))
+						avgGrad[idx] += outGrad[outIdx] / float32(len(indices))
+					}
+					maxOut[outIdx] = in[maxIdx]
+					maxGrad[maxIdx] += outGrad[outIdx]
+				}
+			}
+		}
+	}
+	return
+}
+
+func TestPool2DCheckShapes(t *testing.T) {
+	vectors := func(p *Pool2D) (in, out *vector32) {
+		in = &vector32{size: p.InputWidth * p.InputHeight * p.InputDepth}
+		out = &vector32{size: p.OutputWidth() * p.OutputHeight() * p.InputDepth}
+		return
+	}
+
+	valid := &Pool2D{InputWidth: 1, InputHeight: 1, InputDepth: 1, WindowWidth: 3,
+		WindowHeight: 3, PaddingX: 1, PaddingY: 1}
+	in, out := vectors(valid)
+	valid.checkShapes(1, in, out)
+
+	bad := []*Pool2D{
+		// Truncating division would give one output column.
+		{InputWidth: 1, InputHeight: 3, InputDepth: 1, WindowWidth: 3,
+			WindowHeight: 3, StrideX: 2},
+		{InputWidth: 3, InputHeight: 1, InputDepth: 1, WindowWidth: 3,
+			WindowHeight: 3, StrideY: 2},
+
+		// The windows lie entirely in padding.
+		{InputWidth: 0, InputHeight: 3, InputDepth: 1, WindowWidth: 3,
+			WindowHeight: 3, PaddingX: 2},
+		{InputWidth: 3, InputHeight: 0, InputDepth: 1, WindowWidth: 2,
+			WindowHeight: 2, PaddingY: 1},
+
+		// The output is too big even though the input is not.
+		{InputWidth: 46340, InputHeight: 46340, InputDepth: 1, WindowWidth: 2,
+			WindowHeight: 2, StrideX: 1, StrideY: 1, PaddingX: 1, PaddingY: 1},
+	}
+	for i, pool := range bad {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("case %d: expected panic", i)
+				}
+			}()
+			in, out := vectors(pool)
+			pool.checkShapes(1, in, out)
+		}()
+	}
+}
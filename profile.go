@@ -0,0 +1,319 @@
+package cudavec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/unixpickle/cuda"
+)
+
+// maxProfileTimers is the number of profiled calls after
+// which their times are read, so that the CUDA events that
+// time them can be freed.
+const maxProfileTimers = 1024
+
+// A Profile records the kernels and cuBLAS calls run by a
+// Handle while profiling was enabled.
+type Profile struct {
+	// Ops aggregates the events by name, sorted by total
+	// time in descending order.
+	Ops []*ProfileOp
+
+	// Events stores every event in the order it ran.
+	Events []*ProfileEvent
+
+	// timers stores the CUDA events for the events whose
+	// times have not been read yet.
+	timers []*eventTimer
+
+	// origin is recorded before the first event, and the
+	// start of every event is measured from it.
+	origin *timingEvent
+
+	// startTime is the host time when origin was recorded,
+	// which approximates when it completed on the device.
+	startTime time.Time
+
+	// err is the first error from reading the timers.
+	err error
+}
+
+type eventTimer struct {
+	event      *ProfileEvent
+	start, end *timingEvent
+}
+
+// A ProfileOp aggregates the events for one kernel or
+// cuBLAS routine.
+type ProfileOp struct {
+	Name  string
+	Count int
+	Total time.Duration
+	Bytes uint64
+}
+
+// A ProfileEvent records a single kernel launch or cuBLAS
+// call.
+//
+// Bytes is the total size of the buffers passed to the
+// call, which bounds the memory it touches.
+type ProfileEvent struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	Bytes    uint64
+}
+
+// StartProfiling starts recording every kernel launch and
+// cuBLAS call on the Handle.
+//
+// Each call is timed on the device by CUDA events which
+// are recorded on its stream before and after it.
+// The times are read once profiling stops, or once
+// maxProfileTimers calls have been queued since they were
+// last read, which waits for those calls to finish.
+func (h *Handle) StartProfiling() {
+	<-h.context.Run(func() error {
+		h.profile = &Profile{}
+		return nil
+	})
+}
+
+// StopProfiling stops profiling and returns the results.
+//
+// It waits for the profiled calls to finish on the device.
+// It returns nil if profiling was not started.
+// If the times of the calls cannot be read, an error is
+// returned instead.
+func (h *Handle) StopProfiling() (*Profile, error) {
+	var res *Profile
+	var err error
+	<-h.context.Run(func() error {
+		res = h.profile
+		h.profile = nil
+		if res != nil {
+			err = res.close()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res != nil {
+		res.aggregate()
+	}
+	return res, nil
+}
+
+// WriteTable writes a human-readable table of the ops.
+func (p *Profile) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Op\tCount\tTotal\tMean\tBytes\t")
+	for _, op := range p.Ops {
+		mean := op.Total / time.Duration(op.Count)
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t\n", op.Name, op.Count, op.Total, mean,
+			op.Bytes)
+	}
+	return tw.Flush()
+}
+
+// WriteChromeTrace writes the events in the Chrome trace
+// event format, which can be viewed in chrome://tracing
+// or Perfetto.
+func (p *Profile) WriteChromeTrace(w io.Writer) error {
+	type traceEvent struct {
+		Name  string            `json:"name"`
+		Phase string            `json:"ph"`
+		Time  float64           `json:"ts"`
+		Dur   float64           `json:"dur"`
+		PID   int               `json:"pid"`
+		TID   int               `json:"tid"`
+		Args  map[string]uint64 `json:"args"`
+	}
+	var events []traceEvent
+	if len(p.Events) > 0 {
+		start := p.Events[0].Start
+		for _, event := range p.Events {
+			events = append(events, traceEvent{
+				Name:  event.Name,
+				Phase: "X",
+				Time:  float64(event.Start.Sub(start)) / float64(time.Microsecond),
+				Dur:   float64(event.Duration) / float64(time.Microsecond),
+				Args:  map[string]uint64{"bytes": event.Bytes},
+			})
+		}
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ns",
+	})
+}
+
+// recordOrigin records the event that the start of every
+// event is measured from.
+func (p *Profile) recordOrigin(stream *cuda.Stream) error {
+	origin, err := newTimingEvent()
+	if err != nil {
+		return err
+	}
+	if err := origin.Record(stream); err != nil {
+		origin.Destroy()
+		return err
+	}
+	p.origin = origin
+	p.startTime = time.Now()
+	return nil
+}
+
+// readTimers waits for the timed calls to finish, sets
+// the times of their events, and frees the CUDA events.
+//
+// After an error, which is stored in p.err, the timers
+// are freed without being read.
+func (p *Profile) readTimers() {
+	for i, timer := range p.timers {
+		if p.err == nil {
+			p.err = timer.read(p.origin, p.startTime)
+		}
+		timer.destroy()
+		p.timers[i] = nil
+	}
+	p.timers = p.timers[:0]
+}
+
+// close reads the remaining timers and frees the origin.
+func (p *Profile) close() error {
+	p.readTimers()
+	if p.origin != nil {
+		p.origin.Destroy()
+		p.origin = nil
+	}
+	return p.err
+}
+
+func (p *Profile) aggregate() {
+	ops := map[string]*ProfileOp{}
+	p.Ops = nil
+	for _, event := range p.Events {
+		op, ok := ops[event.Name]
+		if !ok {
+			op = &ProfileOp{Name: event.Name}
+			ops[event.Name] = op
+			p.Ops = append(p.Ops, op)
+		}
+		op.Count++
+		op.Total += event.Duration
+		op.Bytes += event.Bytes
+	}
+	sort.SliceStable(p.Ops, func(i, j int) bool {
+		return p.Ops[i].Total > p.Ops[j].Total
+	})
+}
+
+// launch launches a kernel from kernels32, profiling it
+// if necessary.
+func (h *Handle) launch(name string, gridX, gridY, gridZ, blockX, blockY, blockZ,
+	shared uint, stream *cuda.Stream, args ...interface{}) error {
+	return h.profileCall(name, stream, args, func() error {
+		return h.kernels32.Launch(name, gridX, gridY, gridZ, blockX, blockY, blockZ,
+			shared, stream, args...)
+	})
+}
+
+// blasCall runs a cuBLAS routine, profiling it if
+// necessary.
+func (h *Handle) blasCall(name string, f func() error, buffers ...cuda.Buffer) error {
+	if h.profile == nil {
+		return f()
+	}
+	args := make([]interface{}, len(buffers))
+	for i, buf := range buffers {
+		args[i] = buf
+	}
+	return h.profileCall(name, nil, args, f)
+}
+
+// profileCall runs f, recording it as an event if the
+// Handle is being profiled.
+//
+// The args are used to compute the number of bytes that f
+// may touch.
+func (h *Handle) profileCall(name string, stream *cuda.Stream, args []interface{},
+	f func() error) error {
+	if h.profile == nil {
+		return f()
+	}
+	if h.profile.origin == nil {
+		if err := h.profile.recordOrigin(stream); err != nil {
+			return err
+		}
+	}
+	timer, err := newEventTimer(name)
+	if err != nil {
+		return err
+	}
+	if err := timer.record(stream, f); err != nil {
+		timer.destroy()
+		return err
+	}
+	for _, arg := range args {
+		if buf, ok := arg.(cuda.Buffer); ok {
+			timer.event.Bytes += uint64(buf.Size())
+		}
+	}
+	h.profile.timers = append(h.profile.timers, timer)
+	h.profile.Events = append(h.profile.Events, timer.event)
+	if len(h.profile.timers) >= maxProfileTimers {
+		h.profile.readTimers()
+	}
+	return nil
+}
+
+func newEventTimer(name string) (*eventTimer, error) {
+	start, err := newTimingEvent()
+	if err != nil {
+		return nil, err
+	}
+	end, err := newTimingEvent()
+	if err != nil {
+		start.Destroy()
+		return nil, err
+	}
+	return &eventTimer{event: &ProfileEvent{Name: name}, start: start, end: end}, nil
+}
+
+// record runs f between the timer's start and end events.
+func (e *eventTimer) record(stream *cuda.Stream, f func() error) error {
+	if err := e.start.Record(stream); err != nil {
+		return err
+	}
+	if err := f(); err != nil {
+		return err
+	}
+	return e.end.Record(stream)
+}
+
+// read waits for the timed call to finish and sets the
+// time of its event.
+func (e *eventTimer) read(origin *timingEvent, startTime time.Time) error {
+	offset, err := e.start.Since(origin)
+	if err != nil {
+		return err
+	}
+	duration, err := e.end.Since(e.start)
+	if err != nil {
+		return err
+	}
+	e.event.Start = startTime.Add(offset)
+	e.event.Duration = duration
+	return nil
+}
+
+func (e *eventTimer) destroy() {
+	e.start.Destroy()
+	e.end.Destroy()
+}
@@ -0,0 +1,134 @@
+package cudavec
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileOutput(t *testing.T) {
+	start := time.Now()
+	profile := &Profile{
+		Events: []*ProfileEvent{
+			{Name: "addScaler", Start: start, Duration: time.Millisecond, Bytes: 40},
+			{Name: "Sgemm", Start: start.Add(time.Millisecond),
+				Duration: 5 * time.Millisecond, Bytes: 400},
+			{Name: "addScaler", Start: start.Add(6 * time.Millisecond),
+				Duration: 2 * time.Millisecond, Bytes: 40},
+		},
+	}
+	profile.aggregate()
+	if len(profile.Ops) != 2 {
+		t.Fatalf("expected 2 ops but got %d", len(profile.Ops))
+	}
+	expected := []ProfileOp{
+		{Name: "Sgemm", Count: 1, Total: 5 * time.Millisecond, Bytes: 400},
+		{Name: "addScaler", Count: 2, Total: 3 * time.Millisecond, Bytes: 80},
+	}
+	for i, op := range profile.Ops {
+		if *op != expected[i] {
+			t.Errorf("op %d: expected %+v but got %+v", i, expected[i], *op)
+		}
+	}
+
+	var table bytes.Buffer
+	if err := profile.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "Sgemm") ||
+		!strings.Contains(lines[2], "1.5ms") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+
+	var trace bytes.Buffer
+	if err := profile.WriteChromeTrace(&trace); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		TraceEvents []struct {
+			Name string  `json:"name"`
+			Ph   string  `json:"ph"`
+			Ts   float64 `json:"ts"`
+			Dur  float64 `json:"dur"`
+		} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(trace.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.TraceEvents) != 3 {
+		t.Fatalf("expected 3 trace events but got %d", len(decoded.TraceEvents))
+	}
+	last := decoded.TraceEvents[2]
+	if last.Name != "addScaler" || last.Ph != "X" || last.Ts != 6000 || last.Dur != 2000 {
+		t.Errorf("unexpected trace event: %+v", last)
+	}
+}
+
+func TestProfiling(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	vec := c.MakeVectorData(randomSlice(100))
+	handle.StartProfiling()
+	vec.AddScalar(float32(1))
+	vec.AddScalar(float32(2))
+	vec.Dot(vec)
+	profile, err := handle.StopProfiling()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	for _, op := range profile.Ops {
+		counts[op.Name] = op.Count
+	}
+	if counts["addScaler"] != 2 || counts["Sdot"] != 1 {
+		t.Errorf("unexpected op counts: %v", counts)
+	}
+	for i, event := range profile.Events {
+		if event.Duration <= 0 {
+			t.Errorf("event %d (%s) has no duration", i, event.Name)
+		}
+		if i > 0 && event.Start.Before(profile.Events[i-1].Start) {
+			t.Errorf("event %d (%s) starts before the previous event", i, event.Name)
+		}
+	}
+	if profile, err := handle.StopProfiling(); profile != nil || err != nil {
+		t.Error("profiling did not stop")
+	}
+}
+
+func TestProfilingManyCalls(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	vec := c.MakeVectorData(randomSlice(100))
+	handle.StartProfiling()
+	numCalls := maxProfileTimers*2 + 10
+	for i := 0; i < numCalls; i++ {
+		vec.AddScalar(float32(1))
+	}
+	<-handle.context.Run(func() error {
+		if n := len(handle.profile.timers); n >= maxProfileTimers {
+			t.Errorf("expected fewer than %d timers but got %d", maxProfileTimers, n)
+		}
+		return nil
+	})
+	profile, err := handle.StopProfiling()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profile.Events) != numCalls {
+		t.Fatalf("expected %d events but got %d", numCalls, len(profile.Events))
+	}
+	for i, event := range profile.Events {
+		if event.Duration <= 0 {
+			t.Errorf("event %d has no duration", i)
+		} else if i > 0 && event.Start.Before(profile.Events[i-1].Start) {
+			t.Errorf("event %d starts before the previous event", i)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package cudavec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxSafeTensorsHeader is the largest header accepted by
+// OpenSafeTensors, matching the reference implementation.
+const maxSafeTensorsHeader = 100 << 20
+
+var safeTensorsFormats = map[string]*floatFormat{
+	"F16":  float16Format,
+	"BF16": bfloat16Format,
+	"F32":  float32Format,
+	"F64":  float64Format,
+}
+
+// SafeTensorsFile is a memory-mapped safetensors file.
+//
+// Tensors are copied from the mapping to the device a
+// chunk at a time, so they are never fully copied into
+// host memory.
+//
+// It is safe to load tensors from multiple goroutines.
+type SafeTensorsFile struct {
+	// Metadata stores the free-form metadata of the file.
+	Metadata map[string]string
+
+	// lock prevents the file from being unmapped while
+	// tensors are being loaded from it.
+	lock   sync.RWMutex
+	closed bool
+
+	data    []byte
+	unmap   func() error
+	tensors map[string]*safeTensorInfo
+}
+
+type safeTensorInfo struct {
+	DType       string `json:"dtype"`
+	Shape       []int  `json:"shape"`
+	DataOffsets [2]int `json:"data_offsets"`
+}
+
+// OpenSafeTensors memory-maps a safetensors file and
+// validates its header.
+//
+// The file should be closed with Close once all of the
+// needed tensors have been loaded.
+func OpenSafeTensors(path string) (file *SafeTensorsFile, err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("open safetensors: " + err.Error())
+		}
+	}()
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := &SafeTensorsFile{data: data, unmap: unmap}
+	if err := res.parseHeader(); err != nil {
+		unmap()
+		return nil, err
+	}
+	return res, nil
+}
+
+// Names returns the sorted names of the tensors.
+func (s *SafeTensorsFile) Names() []string {
+	var res []string
+	for name := range s.tensors {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Shape returns the shape of a tensor, or nil if there is
+// no tensor with the given name.
+func (s *SafeTensorsFile) Shape(name string) []int {
+	info, ok := s.tensors[name]
+	if !ok {
+		return nil
+	}
+	return append([]int{}, info.Shape...)
+}
+
+// Load copies a tensor to the device, converting it to
+// float32.
+//
+// It returns an error if the file has been closed.
+func (s *SafeTensorsFile) Load(name string, c *Creator32) (*Tensor, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return nil, errors.New("load safetensors: file is closed")
+	}
+	info, ok := s.tensors[name]
+	if !ok {
+		return nil, fmt.Errorf("load safetensors: no tensor named %q", name)
+	}
+	data := s.data[info.DataOffsets[0]:info.DataOffsets[1]]
+	format := safeTensorsFormats[info.DType]
+	vec, err := readVectorData(bytes.NewReader(data), c, len(data)/format.size, format)
+	if err != nil {
+		return nil, errors.New("load safetensors: " + err.Error())
+	}
+	return &Tensor{Vector: vec, Shape: append([]int{}, info.Shape...)}, nil
+}
+
+// Close unmaps the file.
+//
+// It waits for any calls to Load to finish.
+// Closing a file twice returns an error.
+func (s *SafeTensorsFile) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return errors.New("close safetensors: file is already closed")
+	}
+	s.closed = true
+	s.data = nil
+	return s.unmap()
+}
+
+func (s *SafeTensorsFile) parseHeader() error {
+	if len(s.data) < 8 {
+		return errors.New("file too short")
+	}
+	headerSize := binary.LittleEndian.Uint64(s.data)
+	if headerSize > maxSafeTensorsHeader || headerSize > uint64(len(s.data)-8) {
+		return errors.New("invalid header size")
+	}
+	headerData := s.data[8 : 8+headerSize]
+	if !bytes.HasPrefix(headerData, []byte("{")) {
+		return errors.New("header is not a JSON object")
+	}
+	var rawHeader map[string]json.RawMessage
+	if err := json.Unmarshal(headerData, &rawHeader); err != nil {
+		return errors.New("invalid header: " + err.Error())
+	}
+
+	body := s.data[8+headerSize:]
+	s.data = body
+	s.tensors = map[string]*safeTensorInfo{}
+	var infos []*safeTensorInfo
+	for name, raw := range rawHeader {
+		if name == "__metadata__" {
+			if err := json.Unmarshal(raw, &s.Metadata); err != nil {
+				return errors.New("invalid metadata: " + err.Error())
+			}
+			continue
+		}
+		var info safeTensorInfo
+		if err := json.Unmarshal(raw, &info); err != nil {
+			return fmt.Errorf("tensor %q: %s", name, err)
+		}
+		if err := info.validate(len(body)); err != nil {
+			return fmt.Errorf("tensor %q: %s", name, err)
+		}
+		s.tensors[name] = &info
+		infos = append(infos, &info)
+	}
+
+	// Tensors must cover the data without gaps or overlap.
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].DataOffsets[0] < infos[j].DataOffsets[0]
+	})
+	var offset int
+	for _, info := range infos {
+		if info.DataOffsets[0] != offset {
+			return errors.New("tensor data is not contiguous")
+		}
+		offset = info.DataOffsets[1]
+	}
+	if offset != len(body) {
+		return errors.New("tensor data does not fill the file")
+	}
+	return nil
+}
+
+func (s *safeTensorInfo) validate(dataSize int) error {
+	format, ok := safeTensorsFormats[s.DType]
+	if !ok {
+		return errors.New("unsupported dtype: " + s.DType)
+	}
+	for _, x := range s.Shape {
+		if x < 0 {
+			return errors.New("negative dimension in shape")
+		}
+	}
+	size := shapeSize(s.Shape)
+	if size < 0 {
+		return errors.New("tensor is too large")
+	}
+	start, end := s.DataOffsets[0], s.DataOffsets[1]
+	if start < 0 || end < start || end > dataSize {
+		return errors.New("data offsets out of bounds")
+	} else if end-start != size*format.size {
+		return errors.New("data size does not match dtype and shape")
+	}
+	return nil
+}
+
+// WriteSafeTensors encodes tensors as a safetensors file
+// with F32 data.
+//
+// Each vector is copied to the host a chunk at a time.
+// If a tensor's shape is nil, it is one-dimensional.
+func WriteSafeTensors(w io.Writer, tensors map[string]*Tensor,
+	metadata map[string]string) (err error) {
+	defer func() {
+		if err != nil {
+			err = errors.New("write safetensors: " + err.Error())
+		}
+	}()
+	var names []string
+	for name := range tensors {
+		if name == "__metadata__" {
+			return errors.New("reserved tensor name: " + name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := map[string]interface{}{}
+	if metadata != nil {
+		header["__metadata__"] = metadata
+	}
+	var offset int
+	for _, name := range names {
+		tensor := tensors[name]
+		shape := tensor.Shape
+		if shape == nil {
+			shape = []int{tensor.Vector.Len()}
+		}
+		if shapeSize(shape) != tensor.Vector.Len() {
+			return fmt.Errorf("tensor %q: shape does not match vector length", name)
+		}
+		size := tensor.Vector.Len() * 4
+		header[name] = &safeTensorInfo{
+			DType:       "F32",
+			Shape:       shape,
+			DataOffsets: [2]int{offset, offset + size},
+		}
+		offset += size
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// Pad the header so that the data is 8-byte aligned.
+	if len(headerData)%8 != 0 {
+		headerData = append(headerData,
+			[]byte(strings.Repeat(" ", 8-len(headerData)%8))...)
+	}
+	var sizeData [8]byte
+	binary.LittleEndian.PutUint64(sizeData[:], uint64(len(headerData)))
+	if _, err := w.Write(append(sizeData[:], headerData...)); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := writeVectorData(w, tensors[name].Vector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package cudavec
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSafeTensorsRoundTrip(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	dir, err := ioutil.TempDir("", "cudavec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "model.safetensors")
+
+	tensors := map[string]*Tensor{
+		"weight": {Vector: c.MakeVectorData(randomSlice(6)), Shape: []int{2, 3}},
+		"bias":   {Vector: c.MakeVectorData(randomSlice(3))},
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteSafeTensors(f, tensors, map[string]string{"format": "pt"})
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := OpenSafeTensors(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file.Names(), []string{"bias", "weight"}) {
+		t.Errorf("unexpected names: %v", file.Names())
+	}
+	if file.Metadata["format"] != "pt" {
+		t.Errorf("unexpected metadata: %v", file.Metadata)
+	}
+	for name, tensor := range tensors {
+		loaded, err := file.Load(name, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded.Shape, file.Shape(name)) {
+			t.Errorf("%s: unexpected shape: %v", name, loaded.Shape)
+		}
+		assertClose(t, name, loaded.Vector.Data().([]float32),
+			tensor.Vector.Data().([]float32))
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Load("bias", c); err == nil {
+		t.Error("expected error when loading from a closed file")
+	}
+	if err := file.Close(); err == nil {
+		t.Error("expected error when closing twice")
+	}
+}
+
+func TestSafeTensorsHeader(t *testing.T) {
+	bf16 := []byte{0x80, 0x3f, 0x00, 0xc0}
+	makeFile := func(header string, data []byte) []byte {
+		res := make([]byte, 8)
+		binary.LittleEndian.PutUint64(res, uint64(len(header)))
+		return append(append(res, header...), data...)
+	}
+
+	file := &SafeTensorsFile{
+		data: makeFile(`{"x":{"dtype":"BF16","shape":[2],"data_offsets":[0,4]}}`, bf16),
+	}
+	if err := file.parseHeader(); err != nil {
+		t.Fatal(err)
+	}
+	info := file.tensors["x"]
+	decoded := safeTensorsFormats[info.DType].decode(file.data)
+	if !reflect.DeepEqual(decoded, []float32{1, -2}) {
+		t.Errorf("unexpected bfloat16 values: %v", decoded)
+	}
+
+	badHeaders := map[string]string{
+		`{"x":{"dtype":"I8","shape":[4],"data_offsets":[0,4]}}`:   "unsupported dtype",
+		`{"x":{"dtype":"F32","shape":[2],"data_offsets":[0,4]}}`:  "data size",
+		`{"x":{"dtype":"F16","shape":[2],"data_offsets":[2,6]}}`:  "out of bounds",
+		`{"x":{"dtype":"F16","shape":[1],"data_offsets":[2,4]}}`:  "contiguous",
+		`{"x":{"dtype":"F16","shape":[1],"data_offsets":[0,2]}}`:  "fill the file",
+		`{"x":{"dtype":"F16","shape":[-2],"data_offsets":[0,4]}}`: "negative",
+		`[]`: "JSON object",
+	}
+	for header, expected := range badHeaders {
+		file := &SafeTensorsFile{data: makeFile(header, bf16)}
+		err := file.parseHeader()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("header %s: expected %q error but got %v", header, expected, err)
+		}
+	}
+
+	file = &SafeTensorsFile{data: makeFile("{}", nil)}
+	binary.LittleEndian.PutUint64(file.data, math.MaxUint64)
+	if err := file.parseHeader(); err == nil {
+		t.Error("expected error for huge header size")
+	}
+	file = &SafeTensorsFile{data: makeFile(`{"x":{"dtype":"BF16","shape":[2],"data_offsets":[0,4]}}`,
+		bf16), unmap: func() error { return nil }}
+	if err := file.parseHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Load("x", nil); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("expected closed error but got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package cudavec
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// An OverlapError describes an operation which writes to
+// memory that another one of its operands uses.
+type OverlapError struct {
+	// Op is the function that was called.
+	Op string
+
+	// Written is the range that the operation writes.
+	Written VectorRange
+
+	// Other is the range of the operand that overlaps it.
+	Other VectorRange
+
+	// OtherWritten is true if the other operand is also
+	// written by the operation.
+	OtherWritten bool
+}
+
+// Error returns a description of the overlap.
+func (o *OverlapError) Error() string {
+	kind := "read"
+	if o.OtherWritten {
+		kind = "written"
+	}
+	return fmt.Sprintf("invalid overlap in %s: written range %s overlaps %s range %s",
+		o.Op, o.Written, kind, o.Other)
+}
+
+// A VectorRange is the range of components of an
+// underlying buffer that a vector or IntVector refers to.
+type VectorRange struct {
+	// Buffer identifies the underlying buffer.
+	Buffer *int
+
+	Start int
+	End   int
+}
+
+// String formats the range as a buffer and a half-open
+// interval.
+func (v VectorRange) String() string {
+	return fmt.Sprintf("%p[%d:%d]", v.Buffer, v.Start, v.End)
+}
+
+// overlaps checks if two ranges share any components.
+// Unlike vector32.Overlaps, empty ranges never overlap.
+func (v VectorRange) overlaps(v1 VectorRange) bool {
+	return v.Buffer == v1.Buffer && v.Start < v1.End && v1.Start < v.End &&
+		v.Start < v.End && v1.Start < v1.End
+}
+
+// An operand is a vector or IntVector passed to an
+// operation.
+type operand interface {
+	accessRange() VectorRange
+}
+
+func (v *vector32) accessRange() VectorRange {
+	return VectorRange{Buffer: v.bufferID, Start: v.start, End: v.start + v.size}
+}
+
+func (v *IntVector) accessRange() VectorRange {
+	return VectorRange{Buffer: v.bufferID, Start: v.start, End: v.start + v.size}
+}
+
+// SetSanitizer enables or disables strict overlap checks.
+//
+// When enabled, operations check every vector they write
+// against all of their other operands and panic with an
+// *OverlapError on any overlap.
+// Operands that are only read may overlap each other.
+func (h *Handle) SetSanitizer(enabled bool) {
+	var flag int32
+	if enabled {
+		flag = 1
+	}
+	atomic.StoreInt32(&h.sanitizer, flag)
+}
+
+// sanitize panics if the sanitizer is enabled and the
+// operation's operands conflict.
+func (h *Handle) sanitize(writes, reads []operand) {
+	if atomic.LoadInt32(&h.sanitizer) == 0 {
+		return
+	}
+	if err := checkAccess(writes, reads); err != nil {
+		err.Op = callerName()
+		panic(err)
+	}
+}
+
+// checkAccess finds the first written vector which
+// overlaps another operand.
+func checkAccess(writes, reads []operand) *OverlapError {
+	for i, w := range writes {
+		written := w.accessRange()
+		for _, other := range writes[i+1:] {
+			if r := other.accessRange(); written.overlaps(r) {
+				return &OverlapError{Written: written, Other: r, OtherWritten: true}
+			}
+		}
+		for _, other := range reads {
+			if r := other.accessRange(); written.overlaps(r) {
+				return &OverlapError{Written: written, Other: r}
+			}
+		}
+	}
+	return nil
+}
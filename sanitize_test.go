@@ -0,0 +1,159 @@
+package cudavec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckAccess(t *testing.T) {
+	buf1, buf2 := new(int), new(int)
+	slice := func(buf *int, start, end int) *vector32 {
+		return &vector32{size: end - start, bufferID: buf, start: start}
+	}
+	intSlice := func(buf *int, start, end int) *IntVector {
+		return &IntVector{size: end - start, bufferID: buf, start: start}
+	}
+	type access struct {
+		writes, reads []operand
+	}
+	valid := []access{
+		{[]operand{slice(buf1, 0, 10)}, []operand{slice(buf1, 10, 20)}},
+		{[]operand{slice(buf1, 10, 20)}, []operand{slice(buf1, 0, 10)}},
+		{[]operand{slice(buf1, 0, 10)}, []operand{slice(buf2, 0, 10)}},
+		{[]operand{slice(buf1, 0, 10), slice(buf1, 10, 15)}, []operand{slice(buf2, 0, 5)}},
+		{[]operand{slice(buf1, 5, 5)}, []operand{slice(buf1, 0, 10)}},
+		{nil, []operand{slice(buf1, 0, 10), slice(buf1, 0, 10)}},
+		{[]operand{slice(buf1, 0, 5)}, []operand{slice(buf1, 5, 10), slice(buf1, 5, 10)}},
+		{[]operand{intSlice(buf1, 0, 5)}, []operand{intSlice(buf1, 5, 10)}},
+		{[]operand{slice(buf1, 0, 10)}, []operand{intSlice(buf2, 0, 10)}},
+	}
+	for i, a := range valid {
+		if err := checkAccess(a.writes, a.reads); err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err)
+		}
+	}
+
+	invalid := []struct {
+		access
+		written, other [2]int
+		otherWritten   bool
+	}{
+		{access{[]operand{slice(buf1, 0, 10)}, []operand{slice(buf1, 0, 10)}},
+			[2]int{0, 10}, [2]int{0, 10}, false},
+		{access{[]operand{slice(buf1, 0, 10)}, []operand{slice(buf1, 9, 12)}},
+			[2]int{0, 10}, [2]int{9, 12}, false},
+		{access{[]operand{slice(buf1, 5, 10)}, []operand{slice(buf2, 0, 5),
+			slice(buf1, 0, 6)}}, [2]int{5, 10}, [2]int{0, 6}, false},
+		{access{[]operand{slice(buf1, 2, 4)}, []operand{slice(buf1, 0, 10)}},
+			[2]int{2, 4}, [2]int{0, 10}, false},
+		{access{[]operand{slice(buf1, 0, 10), slice(buf1, 3, 4)}, nil},
+			[2]int{0, 10}, [2]int{3, 4}, true},
+		{access{[]operand{intSlice(buf1, 0, 10)}, []operand{intSlice(buf1, 9, 12)}},
+			[2]int{0, 10}, [2]int{9, 12}, false},
+		{access{[]operand{slice(buf2, 0, 4)}, []operand{intSlice(buf1, 0, 4),
+			slice(buf2, 3, 5)}}, [2]int{0, 4}, [2]int{3, 5}, false},
+	}
+	for i, c := range invalid {
+		err := checkAccess(c.writes, c.reads)
+		if err == nil {
+			t.Errorf("case %d: expected error", i)
+			continue
+		}
+		if err.Written.Start != c.written[0] || err.Written.End != c.written[1] ||
+			err.Other.Start != c.other[0] || err.Other.End != c.other[1] ||
+			err.OtherWritten != c.otherWritten {
+			t.Errorf("case %d: unexpected error: %s", i, err)
+		}
+	}
+}
+
+func TestSanitizer(t *testing.T) {
+	h := &Handle{}
+	c := &Creator32{Handle: h}
+	buf := new(int)
+	v1 := &vector32{creator: c, size: 10, bufferID: buf}
+	v2 := &vector32{creator: c, size: 10, bufferID: buf, start: 5}
+
+	expectOverlap := func(name string, f func()) {
+		defer func() {
+			err, ok := recover().(*OverlapError)
+			if !ok {
+				t.Errorf("%s: expected OverlapError", name)
+			} else if !strings.HasSuffix(err.Op, name) {
+				t.Errorf("%s: unexpected op: %s", name, err.Op)
+			} else if !strings.Contains(err.Error(), "[5:15]") {
+				t.Errorf("%s: unexpected message: %s", name, err)
+			}
+		}()
+		f()
+	}
+
+	h.SetSanitizer(true)
+	expectOverlap("Set", func() {
+		v1.Set(v2)
+	})
+	expectOverlap("AddChunks", func() {
+		v1.AddChunks(v2)
+	})
+	expectOverlap("repeatedOp", func() {
+		v1.AddRepeated(v2)
+	})
+	expectOverlap("BatchedGemm", func() {
+		v1.BatchedGemm(false, false, 1, 1, 1, 10, float32(1), v2,
+			&vector32{creator: c, size: 10, bufferID: new(int)}, float32(0))
+	})
+	mapper := &mapper32{creator: c, inSize: 10, outSize: 10}
+	expectOverlap("Map", func() {
+		mapper.Map(v2, v1)
+	})
+	conv := &Conv2D{InputWidth: 10, InputHeight: 1, InputDepth: 1, FilterWidth: 1,
+		FilterHeight: 1, FilterCount: 1}
+	expectOverlap("Im2Col", func() {
+		conv.Im2Col(1, v2, v1)
+	})
+	expectOverlap("Col2Im", func() {
+		conv.Col2Im(1, v2, v1)
+	})
+	pool := &Pool2D{InputWidth: 10, InputHeight: 1, InputDepth: 1, WindowWidth: 1,
+		WindowHeight: 1}
+	expectOverlap("AvgPool", func() {
+		pool.AvgPool(1, v2, v1)
+	})
+	expectOverlap("AvgPoolBackward", func() {
+		pool.AvgPoolBackward(1, v2, v1)
+	})
+	other := func() *vector32 {
+		return &vector32{creator: c, size: 10, bufferID: new(int)}
+	}
+	expectOverlap("LayerNorm", func() {
+		LayerNorm(v2, v1, other(), other(), 10, 1e-5)
+	})
+	stats := &NormStats{Mean: other(), InvStd: other()}
+	expectOverlap("BatchNormBackward", func() {
+		BatchNormBackward(other(), other(), stats, v2, v1, other(), other())
+	})
+	attention := &Attention{Batch: 1, Heads: 1, SeqLen: 10, Dim: 1}
+	expectOverlap("Forward", func() {
+		attention.Forward(v2, other(), other(), v1)
+	})
+	expectOverlap("Backward", func() {
+		attention.Backward(other(), other(), other(), other(), other(), v2, v1,
+			other(), other())
+	})
+	intBuf := new(int)
+	ints1 := &IntVector{creator: c, size: 10, bufferID: intBuf}
+	ints2 := &IntVector{creator: c, size: 10, bufferID: intBuf, start: 5}
+	expectOverlap("Set", func() {
+		ints1.Set(ints2)
+	})
+
+	h.SetSanitizer(false)
+	func() {
+		defer func() {
+			if r := recover(); r != "inputs overlap" {
+				t.Errorf("unexpected panic: %v", r)
+			}
+		}()
+		mapper.MapTranspose(v2, v1)
+	}()
+}
@@ -0,0 +1,109 @@
+package cudavec
+
+import "github.com/unixpickle/cuda"
+
+// minScratchSize is the smallest scratch size class, in
+// bytes.
+const minScratchSize = 256
+
+// maxScratchBytes is the most memory that the free lists
+// of a scratchArena may hold.
+// Buffers released beyond this limit are dropped.
+const maxScratchBytes = 256 << 20
+
+// scratchArena recycles temporary buffers for operations
+// on a Handle.
+//
+// Buffers are grouped into power-of-two size classes.
+// A buffer obtained from the arena is returned to it when
+// the run closure that requested it finishes, and may be
+// reused by the next closure.
+// This is safe because the closures on a Handle execute
+// one at a time and their work is queued on the default
+// stream, so a later kernel cannot start until earlier
+// kernels using the same buffer have finished.
+// Scratch buffers must not be used on other streams.
+//
+// The free lists are dropped when an allocation fails, so
+// that the buffers in them can be reclaimed before it is
+// retried.
+//
+// The arena is only accessed on the context goroutine, so
+// it needs no locking.
+type scratchArena struct {
+	free      map[uintptr][]cuda.Buffer
+	freeBytes uintptr
+	inUse     []cuda.Buffer
+}
+
+// ClearScratch drops all of the cached scratch buffers,
+// allowing them to be freed.
+func (h *Handle) ClearScratch() {
+	<-h.context.Run(func() error {
+		h.scratch.clear()
+		return nil
+	})
+}
+
+// scratchBuffer gets a temporary buffer that lives until
+// the end of the current run closure.
+//
+// The contents of the buffer are undefined.
+// New buffers are attributed to the given site in
+// MemStats.
+func (h *Handle) scratchBuffer(site string, size uintptr) (cuda.Buffer, error) {
+	class := scratchSizeClass(size)
+	var buf cuda.Buffer
+	if list := h.scratch.free[class]; len(list) > 0 {
+		buf = list[len(list)-1]
+		h.scratch.free[class] = list[:len(list)-1]
+		h.scratch.freeBytes -= class
+	} else {
+		var err error
+		buf, err = h.allocBuffer(site, class)
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.scratch.inUse = append(h.scratch.inUse, buf)
+	return cuda.Slice(buf, 0, size), nil
+}
+
+// releaseScratch returns all of the buffers from
+// scratchBuffer to the arena.
+func (h *Handle) releaseScratch() {
+	h.scratch.release()
+}
+
+func (s *scratchArena) release() {
+	if len(s.inUse) == 0 {
+		return
+	}
+	if s.free == nil {
+		s.free = map[uintptr][]cuda.Buffer{}
+	}
+	for i, buf := range s.inUse {
+		class := buf.Size()
+		if s.freeBytes+class <= maxScratchBytes {
+			s.free[class] = append(s.free[class], buf)
+			s.freeBytes += class
+		}
+		s.inUse[i] = nil
+	}
+	s.inUse = s.inUse[:0]
+}
+
+// clear drops the free lists.
+func (s *scratchArena) clear() {
+	s.free = nil
+	s.freeBytes = 0
+}
+
+// scratchSizeClass rounds a size up to a power of two.
+func scratchSizeClass(size uintptr) uintptr {
+	class := uintptr(minScratchSize)
+	for class < size {
+		class *= 2
+	}
+	return class
+}
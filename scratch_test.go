@@ -0,0 +1,94 @@
+package cudavec
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/unixpickle/cuda"
+)
+
+func TestScratchSizeClass(t *testing.T) {
+	cases := map[uintptr]uintptr{
+		0:    minScratchSize,
+		4:    minScratchSize,
+		256:  256,
+		257:  512,
+		4000: 4096,
+		4096: 4096,
+	}
+	for size, expected := range cases {
+		if actual := scratchSizeClass(size); actual != expected {
+			t.Errorf("size %d: expected class %d but got %d", size, expected, actual)
+		}
+	}
+}
+
+func TestScratchReuse(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	vec := c.MakeVectorData(randomSlice(3000)).(*vector32)
+	vec.AddLogs(1000).Data()
+	before := handle.MemStats()
+	for i := 0; i < 3; i++ {
+		vec.AddLogs(1000).Data()
+		vec.Copy().(*vector32).LogSoftmax(1000)
+		vec.Sum()
+	}
+	after := handle.MemStats()
+	if after.Sites[SiteAddLogs].Allocs != before.Sites[SiteAddLogs].Allocs {
+		t.Errorf("AddLogs temporaries were not reused: %d -> %d allocations",
+			before.Sites[SiteAddLogs].Allocs, after.Sites[SiteAddLogs].Allocs)
+	}
+}
+
+func TestScratchLimit(t *testing.T) {
+	var arena scratchArena
+	for i := 0; i < 3; i++ {
+		arena.inUse = append(arena.inUse, fakeScratchBuffer(maxScratchBytes/2))
+	}
+	arena.release()
+	if arena.freeBytes != maxScratchBytes || len(arena.free[maxScratchBytes/2]) != 2 {
+		t.Errorf("unexpected free lists: %d bytes in %d buffers", arena.freeBytes,
+			len(arena.free[maxScratchBytes/2]))
+	}
+	if len(arena.inUse) != 0 {
+		t.Error("buffers are still in use")
+	}
+	arena.clear()
+	if arena.freeBytes != 0 || len(arena.free) != 0 {
+		t.Error("free lists were not cleared")
+	}
+}
+
+func TestScratchTrim(t *testing.T) {
+	stats := newStatsAllocator(newFakeAllocator(1000))
+	stats.policy = OOMPolicy{Retries: 1}
+
+	// Simulate a scratch buffer cached by the arena.
+	cached := new([32]byte)
+	ptr, _ := (&bufferAllocator{statsAllocator: stats}).Alloc(800)
+	stats.SetOwner(ptr, &cached[0])
+	stats.trim = func() {
+		cached = nil
+	}
+	if _, err := stats.Alloc(300); err != nil {
+		t.Fatal(err)
+	}
+	if actual := stats.Stats(); actual.Frees != 1 || actual.AllocatedBytes != 300 {
+		t.Errorf("cached buffer was not reclaimed: %+v", actual)
+	}
+}
+
+type fakeScratchBuffer uintptr
+
+func (f fakeScratchBuffer) Allocator() cuda.Allocator {
+	return nil
+}
+
+func (f fakeScratchBuffer) Size() uintptr {
+	return uintptr(f)
+}
+
+func (f fakeScratchBuffer) WithPtr(func(unsafe.Pointer)) {
+}
@@ -0,0 +1,115 @@
+package cudavec
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// A Tensor is a vector with a shape.
+type Tensor struct {
+	Vector anyvec.Vector
+	Shape  []int
+}
+
+// shapeSize computes the product of a shape, or -1 if a
+// dimension is negative or the product does not fit in an
+// int32.
+func shapeSize(shape []int) int {
+	size := 1
+	for _, x := range shape {
+		if x < 0 || (x != 0 && size > math.MaxInt32/x) {
+			return -1
+		}
+		size *= x
+	}
+	return size
+}
+
+// A floatFormat describes a binary encoding of floating
+// point values.
+type floatFormat struct {
+	size      int
+	byteOrder binary.ByteOrder
+	float     func(bits uint64) float32
+}
+
+// Little-endian formats for common float types.
+var (
+	float16Format = &floatFormat{
+		size:      2,
+		byteOrder: binary.LittleEndian,
+		float: func(bits uint64) float32 {
+			return float16To32(uint16(bits))
+		},
+	}
+	bfloat16Format = &floatFormat{
+		size:      2,
+		byteOrder: binary.LittleEndian,
+		float: func(bits uint64) float32 {
+			return bfloat16To32(uint16(bits))
+		},
+	}
+	float32Format = &floatFormat{
+		size:      4,
+		byteOrder: binary.LittleEndian,
+		float: func(bits uint64) float32 {
+			return math.Float32frombits(uint32(bits))
+		},
+	}
+	float64Format = &floatFormat{
+		size:      8,
+		byteOrder: binary.LittleEndian,
+		float: func(bits uint64) float32 {
+			return float32(math.Float64frombits(bits))
+		},
+	}
+)
+
+func (d *floatFormat) decode(data []byte) []float32 {
+	res := make([]float32, len(data)/d.size)
+	for i := range res {
+		chunk := data[i*d.size : (i+1)*d.size]
+		var bits uint64
+		switch d.size {
+		case 2:
+			bits = uint64(d.byteOrder.Uint16(chunk))
+		case 4:
+			bits = uint64(d.byteOrder.Uint32(chunk))
+		case 8:
+			bits = d.byteOrder.Uint64(chunk)
+		}
+		res[i] = d.float(bits)
+	}
+	return res
+}
+
+// float16To32 converts an IEEE 754 half-precision value
+// to a float32.
+func float16To32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mantissa := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		if mantissa == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Subnormal numbers are normalized for float32.
+		value := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			return -value
+		}
+		return value
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mantissa<<13)
+	}
+}
+
+// bfloat16To32 converts a bfloat16 value to a float32.
+func bfloat16To32(h uint16) float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
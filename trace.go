@@ -0,0 +1,94 @@
+package cudavec
+
+import (
+	"context"
+	"runtime"
+	"runtime/trace"
+	"strings"
+	"time"
+)
+
+// A Tracer is notified of each operation that runs on a
+// Handle.
+//
+// It can be used to export operations as spans to a
+// tracing system such as OpenTelemetry.
+type Tracer interface {
+	// StartOp is called on the context goroutine when an
+	// operation starts running.
+	//
+	// The name is the function that queued the operation,
+	// and queued is the time when it was queued.
+	// The returned function is called once the operation
+	// has been submitted to the device.
+	StartOp(name string, queued time.Time) func()
+}
+
+type tracerBox struct {
+	Tracer Tracer
+}
+
+// SetTracer sets the Tracer for the Handle.
+//
+// A nil Tracer disables tracing.
+func (h *Handle) SetTracer(t Tracer) {
+	h.tracer.Store(tracerBox{t})
+}
+
+// traceOp wraps an operation so that it shows up in
+// execution traces and in the Handle's Tracer.
+//
+// It must be called from the goroutine that is queueing
+// the operation.
+// If tracing is disabled, f is returned unchanged.
+func (h *Handle) traceOp(f func() error) func() error {
+	tracer := h.currentTracer()
+	if !trace.IsEnabled() && tracer == nil {
+		return f
+	}
+	name := callerName()
+	queued := time.Now()
+	ctx, task := trace.NewTask(context.Background(), name)
+	trace.Log(ctx, "cudavec", "queued")
+	return func() error {
+		defer task.End()
+		trace.Logf(ctx, "cudavec", "waited %s", time.Since(queued))
+		if tracer != nil {
+			defer tracer.StartOp(name, queued)()
+		}
+		defer trace.StartRegion(ctx, name).End()
+		return f()
+	}
+}
+
+func (h *Handle) currentTracer() Tracer {
+	box, _ := h.tracer.Load().(tracerBox)
+	return box.Tracer
+}
+
+// callerName finds the first function on the stack that
+// is not part of the machinery for queueing operations.
+func callerName() string {
+	pcs := make([]uintptr, maxStackDepth)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !isQueueFunc(frame.Function) || !more {
+			return frame.Function
+		}
+	}
+}
+
+func isQueueFunc(name string) bool {
+	if !strings.Contains(name, "cudavec.") {
+		return false
+	}
+	for _, suffix := range []string{".traceOp", ".checkFinite", ".run", ".runSync",
+		".sanitize", ".assertCompat", ".checkAccess", ".checkNormArgs",
+		".checkNormGradArgs"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package cudavec
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	lock  sync.Mutex
+	names []string
+	ended int
+}
+
+func (r *recordingTracer) StartOp(name string, queued time.Time) func() {
+	r.lock.Lock()
+	r.names = append(r.names, name)
+	r.lock.Unlock()
+	return func() {
+		r.lock.Lock()
+		r.ended++
+		r.lock.Unlock()
+	}
+}
+
+func TestCallerName(t *testing.T) {
+	h := &Handle{}
+	var queuedName string
+	h.SetTracer(tracerFunc(func(name string, queued time.Time) func() {
+		queuedName = name
+		return func() {}
+	}))
+	h.traceOp(func() error {
+		return nil
+	})()
+	if !strings.HasSuffix(queuedName, ".TestCallerName") {
+		t.Errorf("unexpected name: %s", queuedName)
+	}
+}
+
+type tracerFunc func(name string, queued time.Time) func()
+
+func (t tracerFunc) StartOp(name string, queued time.Time) func() {
+	return t(name, queued)
+}
+
+func TestTracer(t *testing.T) {
+	handle := setupTest(t)
+	c := &Creator32{Handle: handle}
+
+	tracer := &recordingTracer{}
+	handle.SetTracer(tracer)
+	defer handle.SetTracer(nil)
+	vec := c.MakeVector(10)
+	vec.AddScalar(float32(1))
+	vec.Data()
+
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	if tracer.ended != len(tracer.names) {
+		t.Errorf("%d ops started but %d ended", len(tracer.names), tracer.ended)
+	}
+	var found bool
+	for _, name := range tracer.names {
+		if strings.HasSuffix(name, "AddScalar") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("AddScalar not traced: %v", tracer.names)
+	}
+}
@@ -0,0 +1,240 @@
+package cudavec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+const (
+	// tuneReps is the number of times each candidate is run
+	// while it is being timed.
+	tuneReps = 3
+
+	// maxTuneBytes is the most memory that a benchmark may
+	// copy its buffers into.
+	// Larger launches use the default block size rather
+	// than doubling their memory to be tuned.
+	maxTuneBytes = 64 << 20
+)
+
+var flatCandidates = []uint{32, 64, 128, 256, 512, 1024}
+
+// A TuneCache stores the best block size for each kernel
+// and size bucket, keyed by device name.
+//
+// A TuneCache may be shared between Handles, even if they
+// use different devices.
+type TuneCache struct {
+	lock    sync.Mutex
+	devices map[string]map[string]uint
+}
+
+// NewTuneCache creates an empty TuneCache which is only
+// stored in memory.
+func NewTuneCache() *TuneCache {
+	return &TuneCache{devices: map[string]map[string]uint{}}
+}
+
+// LoadTuneCache loads a TuneCache from a JSON file.
+//
+// If the file does not exist, an empty cache is returned.
+// New results are only stored in memory; call Save to
+// write them back to the file.
+func LoadTuneCache(path string) (cache *TuneCache, err error) {
+	defer essentials.AddCtxTo("load tune cache", &err)
+	cache = NewTuneCache()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.devices); err != nil {
+		return nil, err
+	}
+	if cache.devices == nil {
+		cache.devices = map[string]map[string]uint{}
+	}
+	for device, entries := range cache.devices {
+		for key, block := range entries {
+			if block == 0 || block > 1024 {
+				return nil, fmt.Errorf("bad block size %d for %s on %s", block, key,
+					device)
+			}
+		}
+	}
+	return cache, nil
+}
+
+// Save writes the cache to a JSON file.
+//
+// The file is replaced atomically, so a concurrent reader
+// never sees a partial cache.
+func (t *TuneCache) Save(path string) (err error) {
+	defer essentials.AddCtxTo("save tune cache", &err)
+	t.lock.Lock()
+	data, err := json.MarshalIndent(t.devices, "", "  ")
+	t.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tune")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (t *TuneCache) lookup(device, key string) (uint, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	block, ok := t.devices[device][key]
+	return block, ok
+}
+
+func (t *TuneCache) store(device, key string, block uint) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.devices[device] == nil {
+		t.devices[device] = map[string]uint{}
+	}
+	t.devices[device][key] = block
+}
+
+// SetTuning sets the cache used to pick launch
+// configurations.
+//
+// If benchmark is true, any kernel and size bucket that
+// is missing from the cache is timed with every candidate
+// block size the first time it is launched, and the
+// fastest one is stored in the cache.
+// Otherwise, missing entries fall back to heuristics, so
+// that a cache tuned ahead of time can be used without
+// paying the tuning cost.
+//
+// A nil cache disables tuning.
+func (h *Handle) SetTuning(cache *TuneCache, benchmark bool) {
+	<-h.context.Run(func() error {
+		h.tuneCache = cache
+		h.tuneBenchmark = benchmark
+		return nil
+	})
+}
+
+// A tuneBench runs a kernel with a given block size
+// without affecting the results of any operation.
+type tuneBench func(block uint) error
+
+// tunedBlock finds the block size to use for a kernel.
+//
+// If the block size must be measured, newBench is called
+// to create the benchmark.
+// If newBench returns a nil benchmark, the launch is not
+// tuned and the fallback is used.
+//
+// Candidates whose benchmark fails are skipped, since a
+// kernel may not support every block size, for example
+// because it uses too many registers.
+// If every candidate fails, the last error is returned.
+func (h *Handle) tunedBlock(key string, fallback uint, candidates []uint,
+	newBench func() (tuneBench, error)) (uint, error) {
+	if h.tuneCache == nil {
+		return fallback, nil
+	}
+	if block, ok := h.tuneCache.lookup(h.deviceName, key); ok {
+		return block, nil
+	} else if !h.tuneBenchmark {
+		return fallback, nil
+	}
+
+	// Benchmarks should not show up as operations.
+	profile := h.profile
+	h.profile = nil
+	defer func() {
+		h.profile = profile
+	}()
+
+	bench, err := newBench()
+	if err != nil {
+		return 0, err
+	} else if bench == nil {
+		return fallback, nil
+	}
+	var best uint
+	var bestTime time.Duration
+	var lastErr error
+	for _, block := range candidates {
+		elapsed, err := h.timeBench(bench, block)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best == 0 || elapsed < bestTime {
+			best, bestTime = block, elapsed
+		}
+	}
+	if best == 0 {
+		return 0, lastErr
+	}
+	h.tuneCache.store(h.deviceName, key, best)
+	return best, nil
+}
+
+// timeBench times a benchmark on the device with CUDA
+// events.
+func (h *Handle) timeBench(bench tuneBench, block uint) (time.Duration, error) {
+	timer, err := newEventTimer("")
+	if err != nil {
+		return 0, err
+	}
+	defer timer.destroy()
+
+	// The first run warms up the kernel.
+	if err := bench(block); err != nil {
+		return 0, err
+	}
+	err = timer.record(nil, func() error {
+		for i := 0; i < tuneReps; i++ {
+			if err := bench(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return timer.end.Since(timer.start)
+}
+
+// tuneKey creates a cache key for a kernel and the sizes
+// that its launch depends on.
+//
+// Sizes are bucketed by powers of 2.
+func tuneKey(name string, sizes ...int) string {
+	parts := []string{name}
+	for _, size := range sizes {
+		if size < 0 {
+			size = 0
+		}
+		parts = append(parts, fmt.Sprint(bits.Len(uint(size))))
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,149 @@
+package cudavec
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTuneKey(t *testing.T) {
+	tests := map[string]string{
+		tuneKey("addScaler", 0):       "addScaler/0",
+		tuneKey("addScaler", 1000):    "addScaler/10",
+		tuneKey("addScaler", 1023):    "addScaler/10",
+		tuneKey("addScaler", 1024):    "addScaler/11",
+		tuneKey("mapMax", 1000, 3):    "mapMax/10/2",
+		tuneKey("addLogs", -1, 1<<20): "addLogs/0/21",
+	}
+	for actual, expected := range tests {
+		if actual != expected {
+			t.Errorf("expected %s but got %s", expected, actual)
+		}
+	}
+}
+
+func TestTuneCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cudavec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tune.json")
+
+	cache, err := LoadTuneCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.lookup("dev", "addScaler/10"); ok {
+		t.Fatal("unexpected entry in empty cache")
+	}
+
+	// Stored entries are only written back by Save.
+	cache.store("dev", "addScaler/10", 256)
+	cache.store("other", "addScaler/10", 64)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("cache was saved without calling Save")
+	}
+	if err := cache.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	cache, err = LoadTuneCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block, ok := cache.lookup("dev", "addScaler/10"); !ok || block != 256 {
+		t.Errorf("bad entry: %d, %v", block, ok)
+	}
+	if block, ok := cache.lookup("other", "addScaler/10"); !ok || block != 64 {
+		t.Errorf("bad entry: %d, %v", block, ok)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"dev":{"x/1":0}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTuneCache(path); err == nil {
+		t.Error("expected error for zero block size")
+	}
+	if err := cache.Save(filepath.Join(dir, "missing", "tune.json")); err == nil {
+		t.Error("expected error saving to a missing directory")
+	}
+}
+
+func TestTunedBlockCached(t *testing.T) {
+	cache := NewTuneCache()
+	cache.store("dev", "addScaler/10", 512)
+	h := &Handle{deviceName: "dev"}
+	newBench := func() (tuneBench, error) {
+		t.Fatal("unexpected benchmark")
+		return nil, nil
+	}
+
+	if block, _ := h.tunedBlock("addScaler/10", 128, flatCandidates, newBench); block != 128 {
+		t.Errorf("no cache: expected 128 but got %d", block)
+	}
+
+	h.tuneCache = cache
+	if block, _ := h.tunedBlock("addScaler/10", 128, flatCandidates, newBench); block != 512 {
+		t.Errorf("cached: expected 512 but got %d", block)
+	}
+	if block, _ := h.tunedBlock("addScaler/11", 128, flatCandidates, newBench); block != 128 {
+		t.Errorf("missing: expected 128 but got %d", block)
+	}
+
+	h.deviceName = "other"
+	if block, _ := h.tunedBlock("addScaler/10", 128, flatCandidates, newBench); block != 128 {
+		t.Errorf("other device: expected 128 but got %d", block)
+	}
+
+	// Launches that are too large to benchmark use the
+	// fallback without storing it.
+	h.tuneBenchmark = true
+	noBench := func() (tuneBench, error) {
+		return nil, nil
+	}
+	if block, _ := h.tunedBlock("addScaler/30", 128, flatCandidates, noBench); block != 128 {
+		t.Errorf("untuned: expected 128 but got %d", block)
+	}
+	if _, ok := cache.lookup("other", "addScaler/30"); ok {
+		t.Error("untuned launch was stored")
+	}
+}
+
+func TestTunedBlockFailures(t *testing.T) {
+	h := setupTest(t)
+
+	// Simulate a kernel which cannot be launched with more
+	// than 128 threads per block.
+	errTooLarge := errors.New("too many resources requested for launch")
+	newBench := func() (tuneBench, error) {
+		return func(block uint) error {
+			if block > 128 {
+				return errTooLarge
+			}
+			return nil
+		}, nil
+	}
+
+	var block uint
+	var err, allErr error
+	<-h.context.Run(func() error {
+		oldCache, oldBenchmark := h.tuneCache, h.tuneBenchmark
+		defer func() {
+			h.tuneCache, h.tuneBenchmark = oldCache, oldBenchmark
+		}()
+		h.tuneCache, h.tuneBenchmark = NewTuneCache(), true
+		block, err = h.tunedBlock("test/1", 1024, flatCandidates, newBench)
+		_, allErr = h.tunedBlock("test/2", 1024, []uint{256, 512}, newBench)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if block > 128 {
+		t.Errorf("picked failing block size %d", block)
+	}
+	if allErr != errTooLarge {
+		t.Errorf("expected %v when every candidate fails but got %v", errTooLarge, allErr)
+	}
+}
@@ -16,6 +16,10 @@ type vector32 struct {
 
 	// May be nil for lazy evaluations.
 	buffer cuda.Buffer
+
+	// Stores the contents of a vector decoded by gob until
+	// a GobDecoder binds it to a Creator32.
+	gobData []float32
 }
 
 func (v *vector32) Creator() anyvec.Creator {
@@ -60,7 +64,7 @@ func (v *vector32) SetData(d anyvec.NumericList) {
 func (v *vector32) Set(other anyvec.Vector) {
 	v1 := other.(*vector32)
 	v.assertCompat(v1, false)
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		buf1 := v1.buffer
 		if buf1 == nil {
 			if v.buffer != nil {
@@ -91,7 +95,7 @@ func (v *vector32) Slice(start, end int) anyvec.Vector {
 		bufferID: v.bufferID,
 		start:    v.start + start,
 	}
-	v.run(func() (err error) {
+	v.run(nil, func() (err error) {
 		if err := v.lazyInit(true); err != nil {
 			return err
 		}
@@ -103,23 +107,25 @@ func (v *vector32) Slice(start, end int) anyvec.Vector {
 
 func (v *vector32) Scale(s anyvec.Numeric) {
 	scaler := s.(float32)
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if v.buffer == nil {
 			return nil
 		}
-		return v.creator.Handle.blas.Sscal(v.Len(), scaler, v.buffer, 1)
+		h := v.creator.Handle
+		return h.blasCall("Sscal", func() error {
+			return h.blas.Sscal(v.Len(), scaler, v.buffer, 1)
+		}, v.buffer)
 	})
 }
 
 func (v *vector32) AddScalar(s anyvec.Numeric) {
 	scaler := s.(float32)
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := v.lazyInit(true); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("addScaler", grid, 1, 1,
-			block, 1, 1, 0, nil, scaler, v.buffer, v.Len())
+		return v.creator.Handle.launchFlat("addScaler", v.Len(),
+			scaler, v.buffer, int64(v.Len()))
 	})
 }
 
@@ -131,7 +137,10 @@ func (v *vector32) Dot(other anyvec.Vector) anyvec.Numeric {
 		if err := lazyInitAll(true, v, v1); err != nil {
 			return err
 		}
-		return v.creator.Handle.blas.Sdot(v.Len(), v.buffer, 1, v1.buffer, 1, &res)
+		h := v.creator.Handle
+		return h.blasCall("Sdot", func() error {
+			return h.blas.Sdot(v.Len(), v.buffer, 1, v1.buffer, 1, &res)
+		}, v.buffer, v1.buffer)
 	})
 	return res
 }
@@ -150,25 +159,27 @@ func (v *vector32) Mul(other anyvec.Vector) {
 	if v.Len() == 0 {
 		return
 	}
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, v1); err != nil {
 			return err
 		}
-		return v.creator.Handle.blas.Sdgmm(cublas.Left, v.Len(), 1,
-			v.buffer, v.Len(), v1.buffer, 1, v.buffer, v.Len())
+		h := v.creator.Handle
+		return h.blasCall("Sdgmm", func() error {
+			return h.blas.Sdgmm(cublas.Left, v.Len(), 1,
+				v.buffer, v.Len(), v1.buffer, 1, v.buffer, v.Len())
+		}, v.buffer, v1.buffer)
 	})
 }
 
 func (v *vector32) Div(other anyvec.Vector) {
 	v1 := other.(*vector32)
 	v.assertCompat(v1, false)
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, v1); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("divElements", grid, 1, 1,
-			block, 1, 1, 0, nil, v.buffer, v1.buffer, v.Len())
+		return v.creator.Handle.launchFlat("divElements", v.Len(),
+			v.buffer, v1.buffer, int64(v.Len()))
 	})
 }
 
@@ -179,10 +190,11 @@ func (v *vector32) Gemm(transA, transB bool, m, n, k int,
 	betaFloat := beta.(float32)
 	a32 := a.(*vector32)
 	b32 := b.(*vector32)
+	v.creator.Handle.sanitize([]operand{v}, []operand{a32, b32})
 	if v.Overlaps(a32) || v.Overlaps(b32) {
 		panic("invalid overlap")
 	}
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, a32, b32); err != nil {
 			return err
 		}
@@ -194,9 +206,12 @@ func (v *vector32) Gemm(transA, transB bool, m, n, k int,
 		if transB {
 			tb = cublas.Trans
 		}
-		return v.creator.Handle.blas.Sgemm(tb, ta, n, m, k,
-			alphaFloat, b32.buffer, ldb, a32.buffer, lda,
-			betaFloat, v.buffer, ldc)
+		h := v.creator.Handle
+		return h.blasCall("Sgemm", func() error {
+			return h.blas.Sgemm(tb, ta, n, m, k,
+				alphaFloat, b32.buffer, ldb, a32.buffer, lda,
+				betaFloat, v.buffer, ldc)
+		}, b32.buffer, a32.buffer, v.buffer)
 	})
 }
 
@@ -206,10 +221,11 @@ func (v *vector32) Gemv(trans bool, m, n int, alpha anyvec.Numeric, a anyvec.Vec
 	betaFloat := beta.(float32)
 	x32 := x.(*vector32)
 	a32 := a.(*vector32)
+	v.creator.Handle.sanitize([]operand{v}, []operand{x32, a32})
 	if v.Overlaps(x32) || v.Overlaps(a32) {
 		panic("invalid overlap")
 	}
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, x32, a32); err != nil {
 			return err
 		}
@@ -217,15 +233,19 @@ func (v *vector32) Gemv(trans bool, m, n int, alpha anyvec.Numeric, a anyvec.Vec
 		if trans {
 			tA = cublas.NoTrans
 		}
-		return v.creator.Handle.blas.Sgemv(tA, n, m, alphaFloat,
-			a32.buffer, lda, x32.buffer, incx,
-			betaFloat, v.buffer, incy)
+		h := v.creator.Handle
+		return h.blasCall("Sgemv", func() error {
+			return h.blas.Sgemv(tA, n, m, alphaFloat,
+				a32.buffer, lda, x32.buffer, incx,
+				betaFloat, v.buffer, incy)
+		}, a32.buffer, x32.buffer, v.buffer)
 	})
 }
 
 func (v *vector32) axpy(scaler float32, v1 *vector32) {
 	v.assertCompat(v1, false)
-	v.run(func() error {
+	h := v.creator.Handle
+	v.run([]*vector32{v}, func() error {
 		if v1.buffer == nil {
 			return nil
 		} else if v.buffer == nil {
@@ -238,15 +258,23 @@ func (v *vector32) axpy(scaler float32, v1 *vector32) {
 			if scaler == 1 {
 				return nil
 			}
-			return v.creator.Handle.blas.Sscal(v.Len(), scaler, v.buffer, 1)
+			return h.blasCall("Sscal", func() error {
+				return h.blas.Sscal(v.Len(), scaler, v.buffer, 1)
+			}, v.buffer)
 		}
-		return v.creator.Handle.blas.Saxpy(v.Len(), scaler, v1.buffer, 1,
-			v.buffer, 1)
+		return h.blasCall("Saxpy", func() error {
+			return h.blas.Saxpy(v.Len(), scaler, v1.buffer, 1, v.buffer, 1)
+		}, v1.buffer, v.buffer)
 	})
 }
 
-func (v *vector32) run(f func() error) <-chan error {
-	return v.creator.run(f)
+// run queues an operation which writes to the given
+// vectors.
+//
+// If the Handle's finite check is enabled, the written
+// vectors are checked for non-finite values afterwards.
+func (v *vector32) run(writes []*vector32, f func() error) <-chan error {
+	return v.creator.run(v.creator.Handle.checkFinite(writes, f))
 }
 
 func (v *vector32) runSync(f func() error) {
@@ -258,7 +286,7 @@ func (v *vector32) lazyInit(clear bool) error {
 		return nil
 	}
 	var err error
-	v.buffer, err = cuda.AllocBuffer(v.creator.Handle.allocator, uintptr(v.Len())*4)
+	v.buffer, err = v.creator.Handle.allocBuffer(SiteLazyInit, uintptr(v.Len())*4)
 	if err != nil {
 		return err
 	}
@@ -269,6 +297,9 @@ func (v *vector32) lazyInit(clear bool) error {
 }
 
 func (v *vector32) assertCompat(v1 *vector32, readOnly bool) {
+	if !readOnly {
+		v.creator.Handle.sanitize([]operand{v}, []operand{v1})
+	}
 	if !readOnly && v.Overlaps(v1) {
 		panic("invalid overlap")
 	} else if v.Len() != v1.Len() {
@@ -276,20 +307,6 @@ func (v *vector32) assertCompat(v1 *vector32, readOnly bool) {
 	}
 }
 
-func (v *vector32) kernelSizes() (grid, block uint) {
-	block = 128
-	if uint(v.Len()) < block {
-		block = uint(v.Len())
-		grid = 1
-	} else {
-		grid = uint(v.Len()) / block
-		if uint(v.Len())%block != 0 {
-			grid++
-		}
-	}
-	return
-}
-
 func lazyInitAll(clear bool, vs ...*vector32) error {
 	for _, x := range vs {
 		if err := x.lazyInit(clear); err != nil {
@@ -34,54 +34,77 @@ func (v *vector32) ClipPos() {
 }
 
 func (v *vector32) unaryOp(kernel string) {
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := v.lazyInit(true); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch(kernel, grid, 1, 1, block, 1, 1,
-			0, nil, v.buffer, v.Len())
+		return v.creator.Handle.launchFlat(kernel, v.Len(),
+			v.buffer, int64(v.Len()))
 	})
 }
 
 func (v *vector32) Sum() anyvec.Numeric {
-	ones := v.Creator().MakeVector(v.Len())
-	ones.AddScalar(float32(1))
-	return v.Dot(ones)
+	var res float32
+	if v.Len() == 0 {
+		return res
+	}
+	v.runSync(func() error {
+		if err := v.lazyInit(true); err != nil {
+			return err
+		}
+		ones, err := v.creator.Handle.scratchBuffer(SiteTemp, uintptr(v.Len())*4)
+		if err != nil {
+			return err
+		}
+		err = v.creator.Handle.launchFlat("setScaler", v.Len(),
+			float32(1), ones, int64(v.Len()))
+		if err != nil {
+			return err
+		}
+		h := v.creator.Handle
+		return h.blasCall("Sdot", func() error {
+			return h.blas.Sdot(v.Len(), v.buffer, 1, ones, 1, &res)
+		}, v.buffer, ones)
+	})
+	return res
 }
 
 func (v *vector32) ScaleChunks(other anyvec.Vector) {
 	v1 := other.(*vector32)
+	v.creator.Handle.sanitize([]operand{v}, []operand{v1})
 	if v.Overlaps(v1) {
 		panic("invalid overlap")
 	} else if v.Len()%v1.Len() != 0 {
 		panic("scaler count must divide vector size")
 	}
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, v1); err != nil {
 			return err
 		}
 		rows := v.Len() / v1.Len()
 		cols := v1.Len()
-		return v.creator.Handle.blas.Sdgmm(cublas.Right, rows, cols, v.buffer, rows,
-			v1.buffer, 1, v.buffer, rows)
+		h := v.creator.Handle
+		return h.blasCall("Sdgmm", func() error {
+			return h.blas.Sdgmm(cublas.Right, rows, cols, v.buffer, rows,
+				v1.buffer, 1, v.buffer, rows)
+		}, v.buffer, v1.buffer)
 	})
 }
 
 func (v *vector32) AddChunks(other anyvec.Vector) {
 	v1 := other.(*vector32)
+	v.creator.Handle.sanitize([]operand{v}, []operand{v1})
 	if v.Overlaps(v1) {
 		panic("invalid overlap")
 	} else if v.Len()%v1.Len() != 0 {
 		panic("scaler count must divide vector size")
 	}
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, v1); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("addChunks", grid, 1, 1, block, 1, 1,
-			0, nil, v.buffer, v1.buffer, v.Len(), v.Len()/v1.Len())
+		return v.creator.Handle.launchFlat("addChunks", v.Len(),
+			v.buffer, v1.buffer, int64(v.Len()), v.Len()/v1.Len())
 	})
 }
 
@@ -99,42 +122,40 @@ func (v *vector32) Rand(p anyvec.ProbDist, r *rand.Rand) {
 }
 
 func (v *vector32) randUniform() {
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := v.lazyInit(false); err != nil {
 			return err
 		}
 		if err := v.creator.Handle.gen.Uniform(v.buffer); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("shiftRandUniform", grid, 1, 1,
-			block, 1, 1, 0, nil, v.buffer, v.Len())
+		return v.creator.Handle.launchFlat("shiftRandUniform", v.Len(),
+			v.buffer, int64(v.Len()))
 	})
 }
 
 func (v *vector32) randBernoulli() {
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := v.lazyInit(false); err != nil {
 			return err
 		}
 		if err := v.creator.Handle.gen.Uniform(v.buffer); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("uniformToBernoulli", grid, 1, 1,
-			block, 1, 1, 0, nil, v.buffer, v.Len())
+		return v.creator.Handle.launchFlat("uniformToBernoulli", v.Len(),
+			v.buffer, int64(v.Len()))
 	})
 }
 
 func (v *vector32) randNormal() {
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := v.lazyInit(false); err != nil {
 			return err
 		}
 		if v.Len()%2 == 0 {
 			return v.creator.Handle.gen.Normal(v.buffer, 0, 1)
 		}
-		tempBuf, err := cuda.AllocBuffer(v.creator.Handle.allocator, v.buffer.Size()+4)
+		tempBuf, err := v.creator.Handle.scratchBuffer(SiteTemp, v.buffer.Size()+4)
 		if err != nil {
 			return err
 		}
@@ -154,29 +175,29 @@ func (v *vector32) ScaleRepeated(other anyvec.Vector) {
 }
 
 func (v *vector32) repeatedOp(kernel string, v1 *vector32) {
+	v.creator.Handle.sanitize([]operand{v}, []operand{v1})
 	if v.Overlaps(v1) {
 		panic("invalid overlap")
 	} else if v1.Len() == 0 {
 		panic("repeated vector cannot be empty")
 	}
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, v1); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
 		if isPowerOf2(v1.Len()) {
 			kernel += "Pow2"
-			return v.creator.Handle.kernels32.Launch(kernel, grid, 1, 1, block, 1, 1,
-				0, nil, v.buffer, v1.buffer, v.Len(), v1.Len()-1)
+			return v.creator.Handle.launchFlat(kernel, v.Len(),
+				v.buffer, v1.buffer, int64(v.Len()), v1.Len()-1)
 		} else {
-			return v.creator.Handle.kernels32.Launch(kernel, grid, 1, 1, block, 1, 1,
-				0, nil, v.buffer, v1.buffer, v.Len(), v1.Len())
+			return v.creator.Handle.launchFlat(kernel, v.Len(),
+				v.buffer, v1.buffer, int64(v.Len()), v1.Len())
 		}
 	})
 }
 
 func (v *vector32) AbsSum() anyvec.Numeric {
-	return v.norm(v.creator.Handle.blas.Sasum)
+	return v.norm("Sasum", v.creator.Handle.blas.Sasum)
 }
 
 func (v *vector32) AbsMax() anyvec.Numeric {
@@ -186,7 +207,10 @@ func (v *vector32) AbsMax() anyvec.Numeric {
 			return nil
 		}
 		var idx int
-		err := v.creator.Handle.blas.Isamax(v.Len(), v.buffer, 1, &idx)
+		h := v.creator.Handle
+		err := h.blasCall("Isamax", func() error {
+			return h.blas.Isamax(v.Len(), v.buffer, 1, &idx)
+		}, v.buffer)
 		if err != nil {
 			return err
 		}
@@ -205,16 +229,19 @@ func (v *vector32) AbsMax() anyvec.Numeric {
 }
 
 func (v *vector32) Norm() anyvec.Numeric {
-	return v.norm(v.creator.Handle.blas.Snrm2)
+	return v.norm("Snrm2", v.creator.Handle.blas.Snrm2)
 }
 
-func (v *vector32) norm(f func(int, cuda.Buffer, int, interface{}) error) anyvec.Numeric {
+func (v *vector32) norm(name string,
+	f func(int, cuda.Buffer, int, interface{}) error) anyvec.Numeric {
 	var res float32
 	v.runSync(func() error {
 		if v.buffer == nil {
 			return nil
 		}
-		return f(v.Len(), v.buffer, 1, &res)
+		return v.creator.Handle.blasCall(name, func() error {
+			return f(v.Len(), v.buffer, 1, &res)
+		}, v.buffer)
 	})
 	return res
 }
@@ -232,13 +259,12 @@ func (v *vector32) EqualTo(n anyvec.Numeric) {
 }
 
 func (v *vector32) compare(kernel string, alpha float32) {
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := v.lazyInit(true); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch(kernel, grid, 1, 1, block, 1, 1,
-			0, nil, alpha, v.buffer, v.Len())
+		return v.creator.Handle.launchFlat(kernel, v.Len(),
+			alpha, v.buffer, int64(v.Len()))
 	})
 }
 
@@ -256,7 +282,7 @@ func (v *vector32) AddLogs(chunkSize int) anyvec.Vector {
 
 	res := v.creator.MakeVector(v.Len() / chunkSize).(*vector32)
 
-	v.run(func() error {
+	v.run([]*vector32{res}, func() error {
 		if err := lazyInitAll(true, v, res); err != nil {
 			return err
 		}
@@ -271,9 +297,9 @@ func (v *vector32) AddLogs(chunkSize int) anyvec.Vector {
 }
 
 func (v *vector32) addLogs(rows, cols int, dst, src cuda.Buffer) error {
-	threads := 256
-	for threads/2 >= cols && threads > 32 {
-		threads /= 2
+	threads, err := v.addLogsThreads(rows, cols, src)
+	if err != nil {
+		return err
 	}
 
 	for cols > threads {
@@ -282,7 +308,7 @@ func (v *vector32) addLogs(rows, cols int, dst, src cuda.Buffer) error {
 			dstCols++
 		}
 		dstSize := uintptr(dstCols) * uintptr(rows) * 4
-		tmp, err := cuda.AllocBuffer(v.creator.Handle.allocator, dstSize)
+		tmp, err := v.creator.Handle.scratchBuffer(SiteAddLogs, dstSize)
 		if err != nil {
 			return err
 		}
@@ -296,26 +322,42 @@ func (v *vector32) addLogs(rows, cols int, dst, src cuda.Buffer) error {
 	return v.addLogsKernel(rows, cols, dst, src, threads)
 }
 
+// addLogsThreads picks the block size for addLogs, which
+// must be a power of 2.
+func (v *vector32) addLogsThreads(rows, cols int, src cuda.Buffer) (int, error) {
+	h := v.creator.Handle
+	threads, err := h.tunedBlock(tuneKey("addLogs", rows, cols), normThreads(cols),
+		flatCandidates, func() (tuneBench, error) {
+			return func(block uint) error {
+				dstCols := ceilDiv(cols, int(block))
+				tmp, err := h.scratchBuffer(SiteTemp, uintptr(dstCols*rows)*4)
+				if err != nil {
+					return err
+				}
+				return v.addLogsKernel(rows, cols, tmp, src, int(block))
+			}, nil
+		})
+	return int(threads), err
+}
+
 func (v *vector32) addLogsKernel(rows, cols int, dst, src cuda.Buffer, threads int) error {
-	grid := uint(cols / threads)
-	if cols%threads != 0 {
-		grid++
-	}
+	h := v.creator.Handle
+	gridX := capGrid(rows, h.maxGrid)
+	gridY := capGrid(ceilDiv(cols, threads), defaultMaxGrid)
 	sharedSize := 4 * uint(threads)
-	return v.creator.Handle.kernels32.Launch("addLogs", uint(rows), grid, 1,
-		uint(threads), 1, 1, sharedSize, nil, dst, src, uint(cols))
+	return h.launch("addLogs", gridX, gridY, 1, uint(threads), 1, 1, sharedSize,
+		nil, dst, src, uint(rows), uint(cols))
 }
 
 func (v *vector32) ElemMax(other anyvec.Vector) {
 	v1 := other.(*vector32)
 	v.assertCompat(v1, false)
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, v, v1); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("elemMax", grid, 1, 1, block, 1, 1,
-			0, nil, v.buffer, v1.buffer, v.Len())
+		return v.creator.Handle.launchFlat("elemMax", v.Len(),
+			v.buffer, v1.buffer, int64(v.Len()))
 	})
 }
 
@@ -330,36 +372,34 @@ func (v *vector32) LogSoftmax(chunkSize int) {
 	if v.Len() == 0 {
 		return
 	}
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := v.lazyInit(true); err != nil {
 			return err
 		}
 		size := uintptr(v.Len()/chunkSize) * 4
-		tmp, err := cuda.AllocBuffer(v.creator.Handle.allocator, size)
+		tmp, err := v.creator.Handle.scratchBuffer(SiteAddLogs, size)
 		if err != nil {
 			return err
 		}
 		if err := v.addLogs(v.Len()/chunkSize, chunkSize, tmp, v.buffer); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("subChunks", grid, 1, 1,
-			block, 1, 1, 0, nil, v.buffer, tmp, v.Len(), chunkSize)
+		return v.creator.Handle.launchFlat("subChunks", v.Len(),
+			v.buffer, tmp, int64(v.Len()), chunkSize)
 	})
 }
 
 func (v *vector32) Pow(n anyvec.Numeric) {
 	scaler := n.(float32)
-	v.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if scaler > 0 && v.buffer == nil {
 			return nil
 		}
 		if err := v.lazyInit(true); err != nil {
 			return err
 		}
-		grid, block := v.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("powScaler", grid, 1, 1,
-			block, 1, 1, 0, nil, scaler, v.buffer, v.Len())
+		return v.creator.Handle.launchFlat("powScaler", v.Len(),
+			scaler, v.buffer, int64(v.Len()))
 	})
 }
 
@@ -368,25 +408,25 @@ func (v *vector32) MapMax(cols int) anyvec.Mapper {
 		panic("column count cannot be negative")
 	} else if v.Len()%cols != 0 {
 		panic("column count must divide vector size")
+	} else if int(int32(v.Len())) != v.Len() {
+		panic("mapper size is too big")
 	}
 	if v.Len() == 0 {
 		return newMapper32(v.creator, 0, []int{})
 	}
 	rows := v.Len() / cols
 	res := &mapper32{creator: v.creator, inSize: v.Len(), outSize: rows}
-	v.run(func() error {
+	v.run(nil, func() error {
 		if err := v.lazyInit(true); err != nil {
 			return err
 		}
-		buf, err := cuda.AllocBuffer(v.creator.Handle.allocator, uintptr(rows)*4)
+		buf, err := v.creator.Handle.allocBuffer(SiteMapMax, uintptr(rows)*4)
 		if err != nil {
 			return err
 		}
 		res.table = buf
-		dummyVec := &vector32{size: rows, bufferID: new(int)}
-		grid, block := dummyVec.kernelSizes()
-		return v.creator.Handle.kernels32.Launch("mapMax", grid, 1, 1, block, 1, 1,
-			0, nil, buf, v.buffer, rows, cols)
+		return v.creator.Handle.launchRows("mapMax", rows, cols,
+			buf, v.buffer, rows, cols)
 	})
 	return res
 }
@@ -402,28 +442,29 @@ func (v *vector32) SumRows(cols int) anyvec.Vector {
 	}
 	rows := v.Len() / cols
 	res := v.Creator().MakeVector(cols).(*vector32)
-	v.run(func() error {
+	v.run([]*vector32{res}, func() error {
 		if err := lazyInitAll(true, v, res); err != nil {
 			return err
 		}
-		ones, err := cuda.AllocBuffer(v.creator.Handle.allocator, uintptr(rows)*4)
+		ones, err := v.creator.Handle.scratchBuffer(SiteTemp, uintptr(rows)*4)
 		if err != nil {
 			return err
 		}
-		dummy := vector32{size: rows, bufferID: new(int)}
-		grid, block := dummy.kernelSizes()
-		err = v.creator.Handle.kernels32.Launch("setScaler", grid, 1, 1,
-			block, 1, 1, 0, nil, float32(1), ones, rows)
+		err = v.creator.Handle.launchFlat("setScaler", rows,
+			float32(1), ones, int64(rows))
 		if err != nil {
 			return err
 		}
-		return v.creator.Handle.blas.Sgemm(cublas.NoTrans, cublas.NoTrans,
-			cols, 1, rows,
-			float32(1),
-			v.buffer, cols,
-			ones, rows,
-			float32(0),
-			res.buffer, cols)
+		h := v.creator.Handle
+		return h.blasCall("Sgemm", func() error {
+			return h.blas.Sgemm(cublas.NoTrans, cublas.NoTrans,
+				cols, 1, rows,
+				float32(1),
+				v.buffer, cols,
+				ones, rows,
+				float32(0),
+				res.buffer, cols)
+		}, v.buffer, ones, res.buffer)
 	})
 	return res
 }
@@ -434,10 +475,11 @@ func (v *vector32) BatchedGemm(transA, transB bool, num, m, n, k int, alpha anyv
 	b32 := b.(*vector32)
 	alpha32 := alpha.(float32)
 	beta32 := beta.(float32)
+	v.creator.Handle.sanitize([]operand{v}, []operand{a32, b32})
 	if v.Overlaps(a32) || v.Overlaps(b32) {
 		panic("invalid overlap")
 	}
-	v.creator.run(func() error {
+	v.run([]*vector32{v}, func() error {
 		if err := lazyInitAll(true, a32, b32, v); err != nil {
 			return err
 		}
@@ -479,13 +521,17 @@ func (v *vector32) BatchedGemm(transA, transB bool, num, m, n, k int, alpha anyv
 			if err := v.creator.Handle.blas.SetStream(stream); err != nil {
 				return err
 			}
-			err := v.creator.Handle.blas.Sgemm(tB, tA,
-				n, m, k,
-				alpha32,
-				bBatch[i], ldb,
-				aBatch[i], lda,
-				beta32,
-				subC, n)
+			h := v.creator.Handle
+			args := []interface{}{bBatch[i], aBatch[i], subC}
+			err := h.profileCall("Sgemm", stream, args, func() error {
+				return h.blas.Sgemm(tB, tA,
+					n, m, k,
+					alpha32,
+					bBatch[i], ldb,
+					aBatch[i], lda,
+					beta32,
+					subC, n)
+			})
 			v.creator.Handle.blas.SetStream(nil)
 			if err != nil {
 				return err